@@ -0,0 +1,117 @@
+package wxpay
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fen 以分为单位的金额，账单中以元为单位的金额解析后精确转换为分，JSON中为整数
+type Fen int64
+
+// ParseFen 解析"0.01"、"-1.5"这样以元为单位的金额，最多两位小数，空字符串为0
+func ParseFen(s string) (Fen, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	yuan, fraction, err := splitDecimal(s, 2)
+	if err != nil {
+		return 0, errors.New("invalid amount " + strconv.Quote(s))
+	}
+	fen := yuan*100 + fraction
+	if strings.HasPrefix(s, "-") {
+		fen = -fen
+	}
+	return Fen(fen), nil
+}
+
+// String 返回以元为单位、两位小数的金额
+func (f Fen) String() string {
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+	return sign + strconv.FormatInt(int64(f)/100, 10) + "." + pad(int64(f)%100, 2)
+}
+
+// Rate 费率，单位为百万分之一，例如0.60%为6000，JSON中为整数
+type Rate int64
+
+// ParseRate 解析"0.60%"这样的百分比费率，最多四位小数，空字符串为0
+func ParseRate(s string) (Rate, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	percent, fraction, err := splitDecimal(strings.TrimSuffix(s, "%"), 4)
+	if err != nil || strings.HasPrefix(s, "-") {
+		return 0, errors.New("invalid rate " + strconv.Quote(s))
+	}
+	return Rate(percent*10000 + fraction), nil
+}
+
+// String 返回百分比形式的费率，至少两位小数
+func (r Rate) String() string {
+	fraction := strings.TrimRight(pad(int64(r)%10000, 4), "0")
+	for len(fraction) < 2 {
+		fraction += "0"
+	}
+	return strconv.FormatInt(int64(r)/10000, 10) + "." + fraction + "%"
+}
+
+// 把十进制小数拆成整数部分和放大10^digits倍的小数部分，忽略符号
+func splitDecimal(s string, digits int) (int64, int64, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	integer, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		integer, fraction = s[:i], s[i+1:]
+	}
+	if integer == "" && fraction == "" {
+		return 0, 0, errors.New("empty number")
+	}
+	// 超出精度的部分只能是0
+	if len(fraction) > digits {
+		if strings.Trim(fraction[digits:], "0") != "" {
+			return 0, 0, errors.New("too many decimal places")
+		}
+		fraction = fraction[:digits]
+	}
+	fraction += strings.Repeat("0", digits-len(fraction))
+	if strings.Trim(integer+fraction, "0123456789") != "" {
+		return 0, 0, errors.New("invalid number")
+	}
+
+	var intVal int64
+	var err error
+	if integer != "" {
+		if intVal, err = strconv.ParseInt(integer, 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	fracVal, err := strconv.ParseInt(fraction, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return intVal, fracVal, nil
+}
+
+func pad(n int64, width int) string {
+	s := strconv.FormatInt(n, 10)
+	return strings.Repeat("0", width-len(s)) + s
+}
+
+// 账单中的时间都是北京时间
+var beijing = loadBeijing()
+
+func loadBeijing() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		return loc
+	}
+	// 系统没有时区数据时使用固定的东八区
+	return time.FixedZone("CST", 8*3600)
+}
+
+const billTimeLayout = "2006-01-02 15:04:05"
@@ -0,0 +1,68 @@
+package wxpay
+
+import "testing"
+
+func TestParseFen(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Fen
+	}{
+		{"", 0},
+		{"0", 0},
+		{"0.0", 0},
+		{"0.01", 1},
+		{"0.1", 10},
+		{"12.34", 1234},
+		{"-0.01", -1},
+		{"100", 10000},
+		{".5", 50},
+		{"1.500", 150},
+		{"19.99", 1999},
+	}
+	for _, test := range tests {
+		got, err := ParseFen(test.s)
+		if err != nil || got != test.want {
+			t.Errorf("ParseFen(%q) = %d, %v, want %d", test.s, got, err, test.want)
+		}
+	}
+
+	for _, s := range []string{"0.015", "abc", "1.2.3", "-", "1,00", "1.+5"} {
+		if _, err := ParseFen(s); err == nil {
+			t.Errorf("ParseFen(%q) should fail", s)
+		}
+	}
+}
+
+func TestFen_String(t *testing.T) {
+	for fen, want := range map[Fen]string{0: "0.00", 1: "0.01", 1234: "12.34", -1: "-0.01", -150: "-1.50"} {
+		if got := fen.String(); got != want {
+			t.Errorf("Fen(%d).String() = %s, want %s", fen, got, want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Rate
+		str  string
+	}{
+		{"0.60%", 6000, "0.60%"},
+		{"0.38%", 3800, "0.38%"},
+		{"1%", 10000, "1.00%"},
+		{"0.2%", 2000, "0.20%"},
+		{"0.0125%", 125, "0.0125%"},
+	}
+	for _, test := range tests {
+		got, err := ParseRate(test.s)
+		if err != nil || got != test.want || got.String() != test.str {
+			t.Errorf("ParseRate(%q) = %d(%s), %v", test.s, got, got, err)
+		}
+	}
+
+	for _, s := range []string{"0.00001%", "-0.6%", "x%"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("ParseRate(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package wxpay
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BillArchiver 把对账单的原始gzip文件保存到本地目录，目录结构为：
+//
+//	Dir/1900000109/ALL/20180502.csv.gz         账单
+//	Dir/1900000109/ALL/20180502.csv.gz.sha256  账单的sha256，最后写入，存在说明该日已归档
+//	Dir/1900000109/ALL/20180502.empty          当天没有交易(No Bill Exist)
+//
+// 1900000109为商户号，服务商模式下为"商户号/子商户号"。
+// 文件先写入临时文件再改名，进程中断后重新执行Archive会从未完成的日期继续
+type BillArchiver struct {
+	Client    *Client
+	Dir       string
+	BillTypes []string // 为空时只归档BillTypeAll
+}
+
+func NewBillArchiver(client *Client, dir string, billTypes ...string) *BillArchiver {
+	return &BillArchiver{
+		Client:    client,
+		Dir:       dir,
+		BillTypes: billTypes,
+	}
+}
+
+// Archive 归档from到to(含)之间每一天的账单，日期按北京时间取整到天，已归档的日期跳过，
+// 今天及以后的账单还没有生成，to最晚取到昨天，因此可以用Archive(ctx, from, time.Now())补齐账单。
+// 某一天下载失败时返回错误，之前的日期已保存
+func (a *BillArchiver) Archive(ctx context.Context, from, to time.Time) error {
+	from, to = truncateDay(from), truncateDay(to)
+	if yesterday := truncateDay(time.Now()).AddDate(0, 0, -1); to.After(yesterday) {
+		to = yesterday
+	}
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		billDate := date.Format("20060102")
+		for _, billType := range a.billTypes() {
+			if err := a.ArchiveDate(ctx, billDate, billType); err != nil {
+				return fmt.Errorf("wxpay: archive %s %s: %v", billType, billDate, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ArchiveDate 归档一天的一种账单，已归档时直接返回。
+// 当天及以后的账单还没有生成，返回错误而不是归档为没有交易
+func (a *BillArchiver) ArchiveDate(ctx context.Context, billDate, billType string) error {
+	date, err := time.ParseInLocation("20060102", billDate, beijing)
+	if err != nil {
+		return errors.New("wrong bill_date")
+	}
+	if !date.Before(truncateDay(time.Now())) {
+		return errors.New("bill_date is not before today")
+	}
+
+	archived, err := a.Archived(billDate, billType)
+	if err != nil || archived {
+		return err
+	}
+
+	if err := os.MkdirAll(a.dir(billType), 0755); err != nil {
+		return err
+	}
+
+	request := &DownloadBillRequest{
+		BillDate: billDate,
+		BillType: billType,
+	}
+	if err := a.Client.prepareDownloadBill(ctx, request); err != nil {
+		return err
+	}
+	raw, err := a.Client.downloadGzip(ctx, downloadBillUrl, request)
+	if IsBillNoExist(err) {
+		return writeFileAtomic(a.emptyPath(billDate, billType), nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	path := a.path(billDate, billType)
+	if err := writeFileAtomic(path, raw); err != nil {
+		return err
+	}
+	sum := sha256.Sum256(raw)
+	if err := writeFileAtomic(path+".sha256", []byte(hex.EncodeToString(sum[:])+"  "+filepath.Base(path)+"\n")); err != nil {
+		// 没有sha256的账单不算已归档，删除以免留下无法校验的文件
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// Archived 返回该日的账单是否已经归档，没有交易的日期也算已归档
+func (a *BillArchiver) Archived(billDate, billType string) (bool, error) {
+	for _, path := range []string{a.path(billDate, billType) + ".sha256", a.emptyPath(billDate, billType)} {
+		_, err := os.Stat(path)
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// Open 校验sha256后打开归档的账单，返回解压后的内容，可以用ParseBill解析。
+// 没有交易的日期返回的错误可以用IsBillNoExist判断
+func (a *BillArchiver) Open(billDate, billType string) (io.ReadCloser, error) {
+	if _, err := os.Stat(a.emptyPath(billDate, billType)); err == nil {
+		return nil, billNoExistErr
+	}
+
+	path := a.path(billDate, billType)
+	checksum, err := ioutil.ReadFile(path + ".sha256")
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	if fields := strings.Fields(string(checksum)); len(fields) == 0 || fields[0] != hex.EncodeToString(sum[:]) {
+		return nil, errors.New("wxpay: checksum mismatch: " + path)
+	}
+
+	bill, _, err := decodeBill(path, ioutil.NopCloser(bytes.NewReader(raw)))
+	return bill, err
+}
+
+func (a *BillArchiver) billTypes() []string {
+	if len(a.BillTypes) == 0 {
+		return []string{BillTypeAll}
+	}
+	return a.BillTypes
+}
+
+// 不同商户的账单分目录保存，避免共用Dir时互相覆盖
+func (a *BillArchiver) dir(billType string) string {
+	return filepath.Join(a.Dir, a.Client.mchId, a.Client.subMchId, billType)
+}
+
+func (a *BillArchiver) path(billDate, billType string) string {
+	return filepath.Join(a.dir(billType), billDate+".csv.gz")
+}
+
+func (a *BillArchiver) emptyPath(billDate, billType string) string {
+	return filepath.Join(a.dir(billType), billDate+".empty")
+}
+
+// 按北京时间取整到当天0点
+func truncateDay(t time.Time) time.Time {
+	t = t.In(beijing)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, beijing)
+}
+
+// 先写临时文件再改名，避免中断后留下不完整的文件
+func writeFileAtomic(path string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
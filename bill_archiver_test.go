@@ -0,0 +1,136 @@
+package wxpay
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBillArchiver_Archive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wxpay-bills")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	requests := make(map[string]int)
+	broken := true
+	server := newBillServer(t, func(path string, req Map) string {
+		billDate := req["bill_date"]
+		requests[billDate]++
+		switch {
+		case billDate == "20141111":
+			return "<xml><return_code>FAIL</return_code><return_msg>No Bill Exist</return_msg></xml>"
+		case billDate == "20141112" && broken:
+			return "<xml><return_code>FAIL</return_code><return_msg>invalid bill_date</return_msg></xml>"
+		default:
+			return allBill
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "10000100", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archiver := NewBillArchiver(c, dir)
+	// 非整点的时间按北京时间取整到天，UTC 2014-11-09 20:00 为北京时间 2014-11-10 04:00
+	from := time.Date(2014, 11, 9, 20, 0, 0, 0, time.UTC)
+	to := time.Date(2014, 11, 12, 1, 0, 0, 0, beijing)
+
+	if err := archiver.Archive(context.Background(), from, to); err == nil {
+		t.Fatal("expected error for 20141112")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "10000100", BillTypeAll, "20141112.csv.gz")); !os.IsNotExist(err) {
+		t.Errorf("failed date should not be archived: %v", err)
+	}
+
+	// 重新执行时跳过已归档的日期
+	broken = false
+	if err := archiver.Archive(context.Background(), from, to); err != nil {
+		t.Fatal(err)
+	}
+	if requests["20141110"] != 1 || requests["20141111"] != 1 || requests["20141112"] != 2 {
+		t.Errorf("requests: %v", requests)
+	}
+
+	bill, err := archiver.Open("20141110", BillTypeAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bill.Close()
+	count := 0
+	statistics, err := ParseBill(bill, func(entry *BillEntry) error {
+		count++
+		return nil
+	})
+	if err != nil || count != 2 || len(statistics) != 1 {
+		t.Errorf("count: %d, statistics: %v, err: %v", count, statistics, err)
+	}
+
+	if _, err := archiver.Open("20141111", BillTypeAll); !IsBillNoExist(err) {
+		t.Errorf("err: %v", err)
+	}
+
+	// 文件被修改后校验失败
+	path := filepath.Join(dir, "10000100", BillTypeAll, "20141112.csv.gz")
+	if err := ioutil.WriteFile(path, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archiver.Open("20141112", BillTypeAll); err == nil {
+		t.Error("expected checksum error")
+	}
+}
+
+func TestBillArchiver_ArchiveDate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wxpay-bills")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	requests := 0
+	server := newBillServer(t, func(path string, req Map) string {
+		requests++
+		return "<xml><return_code>FAIL</return_code><return_msg>No Bill Exist</return_msg></xml>"
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "10000100", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport),
+		WithSubMerchant("", "1900000109"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archiver := NewBillArchiver(c, dir)
+
+	// 当天及以后的账单还没有生成，不能归档为没有交易
+	today := time.Now().In(beijing).Format("20060102")
+	if err := archiver.ArchiveDate(context.Background(), today, BillTypeAll); err == nil {
+		t.Error("expected error for today")
+	}
+	if requests != 0 {
+		t.Errorf("requests: %d", requests)
+	}
+
+	// Archive到今天时只归档到昨天
+	if err := archiver.Archive(context.Background(), time.Now().AddDate(0, 0, -2), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("requests: %d", requests)
+	}
+	if archived, _ := archiver.Archived(today, BillTypeAll); archived {
+		t.Error("today should not be archived")
+	}
+
+	if err := archiver.ArchiveDate(context.Background(), "20141111", BillTypeAll); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "10000100", "1900000109", BillTypeAll, "20141111.empty")); err != nil {
+		t.Error(err)
+	}
+}
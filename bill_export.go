@@ -0,0 +1,178 @@
+package wxpay
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导出的字段名为json tag，金额为整数分，费率为整数百万分之一，时间为RFC3339格式的北京时间，空时间为null。
+// 账单的各种记录类型实现了json.Marshaler，直接用encoding/json编码时格式与导出的相同
+
+func (e BillEntry) MarshalJSON() ([]byte, error) {
+	return marshalRecord(&e)
+}
+
+func (s BillStatistics) MarshalJSON() ([]byte, error) {
+	return marshalRecord(&s)
+}
+
+func (e FundFlowEntry) MarshalJSON() ([]byte, error) {
+	return marshalRecord(&e)
+}
+
+func (s FundFlowStatistics) MarshalJSON() ([]byte, error) {
+	return marshalRecord(&s)
+}
+
+// BillJSONWriter 以JSON Lines格式导出账单，每行一条记录。
+// 明细和统计数据的字段不同，建议写入不同的文件
+type BillJSONWriter struct {
+	w io.Writer
+}
+
+func NewBillJSONWriter(w io.Writer) *BillJSONWriter {
+	return &BillJSONWriter{w: w}
+}
+
+// WriteEntry 可以直接作为DownloadBillStream和ParseBill的回调
+func (w *BillJSONWriter) WriteEntry(entry *BillEntry) error {
+	return w.write(entry)
+}
+
+func (w *BillJSONWriter) WriteStatistics(statistics *BillStatistics) error {
+	return w.write(statistics)
+}
+
+func (w *BillJSONWriter) write(v interface{}) error {
+	line, err := marshalRecord(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
+
+func marshalRecord(v interface{}) ([]byte, error) {
+	names, values, extra, err := exportRecord(v)
+	if err != nil {
+		return nil, err
+	}
+	record := make(map[string]interface{}, len(names)+1)
+	for i, name := range names {
+		record[name] = values[i]
+	}
+	if len(extra) > 0 {
+		record["extra"] = extra
+	}
+	return json.Marshal(record)
+}
+
+// BillCSVWriter 导出UTF-8编码的CSV，第一行为英文表头，字段没有`前缀。
+// 一个BillCSVWriter只能写入明细或统计数据中的一种，没有对应字段的列以JSON格式写入extra列。
+// 写完后需要调用Flush
+type BillCSVWriter struct {
+	w          *csv.Writer
+	recordType reflect.Type
+}
+
+func NewBillCSVWriter(w io.Writer) *BillCSVWriter {
+	return &BillCSVWriter{w: csv.NewWriter(w)}
+}
+
+// WriteEntry 可以直接作为DownloadBillStream和ParseBill的回调
+func (w *BillCSVWriter) WriteEntry(entry *BillEntry) error {
+	return w.write(entry)
+}
+
+func (w *BillCSVWriter) WriteStatistics(statistics *BillStatistics) error {
+	return w.write(statistics)
+}
+
+func (w *BillCSVWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *BillCSVWriter) write(v interface{}) error {
+	names, values, extra, err := exportRecord(v)
+	if err != nil {
+		return err
+	}
+	if w.recordType == nil {
+		w.recordType = reflect.TypeOf(v)
+		if err := w.w.Write(append(names, "extra")); err != nil {
+			return err
+		}
+	} else if w.recordType != reflect.TypeOf(v) {
+		return errors.New("wxpay: entries and statistics can not be written to the same csv")
+	}
+
+	record := make([]string, 0, len(values)+1)
+	for _, value := range values {
+		switch value := value.(type) {
+		case nil:
+			record = append(record, "")
+		case int64:
+			record = append(record, strconv.FormatInt(value, 10))
+		default:
+			record = append(record, value.(string))
+		}
+	}
+	if len(extra) > 0 {
+		b, err := json.Marshal(extra)
+		if err != nil {
+			return err
+		}
+		record = append(record, string(b))
+	} else {
+		record = append(record, "")
+	}
+	return w.w.Write(record)
+}
+
+// 按字段顺序返回字段名和导出的值，值为string、int64或nil
+func exportRecord(v interface{}) ([]string, []interface{}, map[string]string, error) {
+	val := reflect.ValueOf(v).Elem()
+	var (
+		names  []string
+		values []interface{}
+		extra  map[string]string
+	)
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		name := strings.Split(val.Type().Field(i).Tag.Get("json"), ",")[0]
+		if name == "extra" {
+			extra = field.Interface().(map[string]string)
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+
+		names = append(names, name)
+		if value, ok := field.Interface().(time.Time); ok {
+			if value.IsZero() {
+				values = append(values, nil)
+			} else {
+				values = append(values, value.In(beijing).Format(time.RFC3339))
+			}
+			continue
+		}
+		switch field.Kind() {
+		case reflect.String:
+			values = append(values, field.String())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			values = append(values, field.Int())
+		default:
+			return nil, nil, nil, fmt.Errorf("wxpay: unsupported field %s of type %s", val.Type().Field(i).Name, field.Type())
+		}
+	}
+	return names, values, extra, nil
+}
@@ -0,0 +1,109 @@
+package wxpay
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBillJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewBillJSONWriter(&buf)
+	statistics, err := ParseBill(strings.NewReader(refundBill), w.WriteEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteStatistics(statistics[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines: %s", buf.String())
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["refund_fee"] != float64(100) || entry["handling_charge"] != float64(-1) || entry["rate"] != float64(6000) ||
+		entry["time_end"] != "2018-05-02T10:28:33+08:00" || entry["sub_mch_id"] != "1900000109" ||
+		entry["extra"].(map[string]interface{})["新增列"] != "x" {
+		t.Errorf("entry: %s", lines[0])
+	}
+
+	var s BillStatistics
+	if err := json.Unmarshal([]byte(lines[1]), &s); err != nil || s.TradeOrderCount != 1 || s.TotalRefundFee != 100 {
+		t.Errorf("statistics: %s, err: %v", lines[1], err)
+	}
+}
+
+func TestBillCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewBillCSVWriter(&buf)
+	statistics, err := ParseBill(strings.NewReader(allBill), w.WriteEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteStatistics(statistics[0]); err == nil {
+		t.Error("expected error when mixing statistics with entries")
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "`") {
+		t.Error("backtick in csv")
+	}
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records: %v", records)
+	}
+	header := records[0]
+	row := make(map[string]string)
+	for i, name := range header {
+		row[name] = records[2][i]
+	}
+	if header[0] != "time_end" || row["time_end"] != "2014-11-10T16:46:14+08:00" || row["total_fee"] != "1" ||
+		row["body"] != `被扫支付"测试"` || row["refund_apply_time"] != "" || row["extra"] != "" {
+		t.Errorf("row: %v", row)
+	}
+}
+
+func TestBillEntry_MarshalJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewBillJSONWriter(&buf)
+	var entries []*BillEntry
+	_, err := ParseBill(strings.NewReader(allBill), func(entry *BillEntry) error {
+		entries = append(entries, entry)
+		return w.WriteEntry(entry)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 直接编码与导出的格式相同，空时间为null
+	b, err := json.Marshal(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.SplitN(buf.String(), "\n", 2)[0]
+	if string(b) != line || !strings.Contains(line, `"refund_apply_time":null`) {
+		t.Errorf("json: %s, exported: %s", b, line)
+	}
+}
+
+func TestExportRecord_UnsupportedKind(t *testing.T) {
+	record := &struct {
+		Name  string  `json:"name"`
+		Ratio float64 `json:"ratio"`
+	}{}
+	if _, _, _, err := exportRecord(record); err == nil {
+		t.Error("expected error for float64 field")
+	}
+}
@@ -1,13 +1,134 @@
 package wxpay
 
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	defaultBaseUrl     = "https://api.mch.weixin.qq.com"
+	defaultRiskBaseUrl = "https://fraud.mch.weixin.qq.com" // 获取RSA公钥的接口使用单独的域名
+)
+
 type Client struct {
-	apiKey string
-	mchId  string
+	apiKey   string
+	mchId    string
+	subAppId string // 服务商模式下的子商户公众账号ID
+	subMchId string // 服务商模式下的子商户号
+	baseUrl  string
+	sandbox  *sandbox
+
+	riskBaseUrl string
+	bankKey     *bankKey // 付款到银行卡使用的RSA公钥
+
+	transport    http.RoundTripper
+	certificates []tls.Certificate
+	httpClient   *http.Client
+	tlsClient    *http.Client // 双向证书
+
+	retryPolicy           RetryPolicy
+	endpointRetryPolicies map[string]RetryPolicy
+	attemptHook           func(attempt *Attempt)
+
+	businessError bool
+	orderLookup   OrderLookup
 }
 
+// Option 用于NewClient时修改Client的配置
+type Option func(c *Client) error
+
 func New(apiKey, mchId string) *Client {
-	return &Client{
-		apiKey: apiKey,
-		mchId:  mchId,
+	c, _ := NewClient(apiKey, mchId)
+	return c
+}
+
+func NewClient(apiKey, mchId string, opts ...Option) (*Client, error) {
+	c := &Client{
+		apiKey:      apiKey,
+		mchId:       mchId,
+		baseUrl:     defaultBaseUrl,
+		riskBaseUrl: defaultRiskBaseUrl,
+		bankKey:     new(bankKey),
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	c.initHttpClients()
+	return c, nil
+}
+
+// WithBaseUrl 修改接口的域名，例如指向本地的httptest服务
+// 可以带路径前缀，如 https://api.mch.weixin.qq.com/sandboxnew
+func WithBaseUrl(baseUrl string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(baseUrl)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return errors.New("invalid base url: " + baseUrl)
+		}
+		c.baseUrl = strings.TrimRight(baseUrl, "/")
+		return nil
+	}
+}
+
+// WithRiskBaseUrl 修改获取RSA公钥接口的域名
+func WithRiskBaseUrl(baseUrl string) Option {
+	return func(c *Client) error {
+		u, err := url.Parse(baseUrl)
+		if err != nil {
+			return err
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return errors.New("invalid base url: " + baseUrl)
+		}
+		c.riskBaseUrl = strings.TrimRight(baseUrl, "/")
+		return nil
+	}
+}
+
+// WithSubMerchant 服务商模式，请求中没有指定sub_appid和sub_mch_id时使用这里的值
+func WithSubMerchant(subAppId, subMchId string) Option {
+	return func(c *Client) error {
+		if subMchId == "" {
+			return errors.New("sub_mch_id is zero")
+		}
+		c.subAppId = subAppId
+		c.subMchId = subMchId
+		return nil
+	}
+}
+
+// SubMerchant 返回服务商代某个子商户调用接口的Client，与c共用证书、http客户端等配置
+func (c *Client) SubMerchant(subAppId, subMchId string) *Client {
+	sub := *c
+	sub.subAppId = subAppId
+	sub.subMchId = subMchId
+	return &sub
+}
+
+func (c *Client) setSubMerchant(subAppId, subMchId *string) {
+	if *subAppId == "" {
+		*subAppId = c.subAppId
+	}
+	if *subMchId == "" {
+		*subMchId = c.subMchId
+	}
+}
+
+// 接口的完整地址
+func (c *Client) url(path string) string {
+	if path == getPublicKeyUrl {
+		return c.riskBaseUrl + path
+	}
+	if c.sandbox != nil {
+		return c.baseUrl + sandboxPrefix + path
 	}
+	return c.baseUrl + path
 }
@@ -0,0 +1,104 @@
+package wxpay
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testApiKey = "192006250b4c09247ec02edce69f6a2d"
+
+// 模拟微信支付的服务端，handler返回的字段会被签名后以xml返回
+func newTestServer(t *testing.T, key string, handler func(path string, req Map) Map) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := make(Map)
+		if err := xml.Unmarshal(body, &req); err != nil {
+			t.Fatal(err)
+		}
+		resp := handler(r.URL.Path, req)
+		resp["sign"] = sign(resp, key, req["sign_type"])
+		var buf bytes.Buffer
+		if err := xml.NewEncoder(&buf).EncodeElement(resp, xml.StartElement{Name: xml.Name{Local: "xml"}}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(buf.Bytes())
+	}))
+}
+
+func TestWithBaseUrl(t *testing.T) {
+	var gotPath string
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		gotPath = path
+		return Map{
+			"return_code":  "SUCCESS",
+			"result_code":  "SUCCESS",
+			"out_trade_no": req["out_trade_no"],
+			"trade_state":  "SUCCESS",
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL+"/sandboxnew/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.OrderQuery(&OrderQueryRequest{OutTradeNo: "1415757673"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotPath != "/sandboxnew/pay/orderquery" {
+		t.Errorf("path: %s", gotPath)
+	}
+	if resp.OutTradeNo != "1415757673" || resp.TradeState != "SUCCESS" {
+		t.Errorf("response: %+v", resp)
+	}
+}
+
+func TestWithBaseUrl_Invalid(t *testing.T) {
+	if _, err := NewClient(testApiKey, "1900000109", WithBaseUrl("api.mch.weixin.qq.com")); err == nil {
+		t.Error("expect error")
+	}
+}
+
+func TestClient_SubMerchant(t *testing.T) {
+	var got Map
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		got = req
+		return Map{
+			"return_code": "SUCCESS",
+			"result_code": "SUCCESS",
+			"sub_mch_id":  req["sub_mch_id"],
+		}
+	})
+	defer server.Close()
+
+	partner, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithSubMerchant("wx8888888888888888", "1900000110"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := partner.CloseOrder(&CloseOrderRequest{OutTradeNo: "1415757673"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["mch_id"] != "1900000109" || got["sub_mch_id"] != "1900000110" || got["sub_appid"] != "wx8888888888888888" {
+		t.Errorf("request: %v", got)
+	}
+	if resp.SubMchId != "1900000110" {
+		t.Errorf("response: %+v", resp)
+	}
+
+	if _, err := partner.SubMerchant("", "1900000111").OrderQuery(&OrderQueryRequest{OutTradeNo: "1415757673"}); err != nil {
+		t.Fatal(err)
+	}
+	if got["sub_mch_id"] != "1900000111" || got["sub_appid"] != "" {
+		t.Errorf("request: %v", got)
+	}
+}
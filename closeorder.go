@@ -1,16 +1,21 @@
 package wxpay
 
-import "encoding/xml"
+import (
+	"context"
+	"encoding/xml"
+)
 
 // https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=9_3
 const (
-	closeOrderUrl = "https://api.mch.weixin.qq.com/pay/closeorder"
+	closeOrderUrl = "/pay/closeorder"
 )
 
 type CloseOrderRequest struct {
 	XMLName    xml.Name `xml:"xml"`
 	AppId      string   `xml:"appid,omitempty"`
 	MchId      string   `xml:"mch_id,omitempty"`
+	SubAppId   string   `xml:"sub_appid,omitempty"`
+	SubMchId   string   `xml:"sub_mch_id,omitempty"`
 	OutTradeNo string   `xml:"out_trade_no,omitempty"`
 	NonceStr   string   `xml:"nonce_str,omitempty"`
 	Sign       string   `xml:"sign,omitempty"`
@@ -21,16 +26,26 @@ type CloseOrderResponse struct {
 	Meta
 	AppId    string `xml:"appid"`
 	MchId    string `xml:"mch_id"`
+	SubAppId string `xml:"sub_appid"`
+	SubMchId string `xml:"sub_mch_id"`
 	NonceStr string `xml:"nonce_str"`
 	Sign     string `xml:"sign"`
 }
 
 func (c *Client) CloseOrder(request *CloseOrderRequest) (*CloseOrderResponse, error) {
+	return c.CloseOrderContext(context.Background(), request)
+}
+
+func (c *Client) CloseOrderContext(ctx context.Context, request *CloseOrderRequest) (*CloseOrderResponse, error) {
 	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
 	request.NonceStr = nonceStr()
-	request.Sign = signStruct(request, c.apiKey)
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response CloseOrderResponse
-	_, err := c.request(closeOrderUrl, request, &response)
+	_, err = c.request(ctx, closeOrderUrl, request, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,145 @@
+package wxpay
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 按表头把csv的列映射到结构体字段。
+// 字段的csv tag为列名，同一字段在不同账单中的列名不同时用|分隔，例如：
+//
+//	type BillEntry struct {
+//		TotalFee string `csv:"应结订单金额|总金额"`
+//	}
+//
+// 没有对应字段的列保存在名为Extra的map[string]string字段中。
+// 字段可以是string、整数、Fen、Rate或time.Time(北京时间)
+type columnMapper struct {
+	header []string
+	fields []int // 每一列对应的字段下标，-1表示没有对应字段
+}
+
+func newColumnMapper(t reflect.Type, header []string) *columnMapper {
+	names := make(map[string]int)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("csv")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		for _, name := range strings.Split(tag, "|") {
+			names[name] = i
+		}
+	}
+
+	m := &columnMapper{
+		header: make([]string, len(header)),
+		fields: make([]int, len(header)),
+	}
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		m.header[i] = name
+		if field, ok := names[name]; ok {
+			m.fields[i] = field
+		} else {
+			m.fields[i] = -1
+		}
+	}
+	return m
+}
+
+// row为数据在账单中的行号，用于错误信息
+func (m *columnMapper) decode(row int, record []string, v interface{}) error {
+	if len(record) != len(m.header) {
+		return fmt.Errorf("wxpay: bill row %d: %d columns, header has %d", row, len(record), len(m.header))
+	}
+
+	val := reflect.ValueOf(v).Elem()
+	for i, value := range record {
+		if field := m.fields[i]; field >= 0 {
+			if err := setColumn(val.Field(field), value); err != nil {
+				return fmt.Errorf("wxpay: bill row %d column %s: %v", row, m.header[i], err)
+			}
+			continue
+		}
+		extra := val.FieldByName("Extra")
+		if !extra.IsValid() {
+			continue
+		}
+		if extra.IsNil() {
+			extra.Set(reflect.MakeMap(extra.Type()))
+		}
+		extra.SetMapIndex(reflect.ValueOf(m.header[i]), reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func setColumn(field reflect.Value, value string) error {
+	var err error
+	switch v := field.Addr().Interface().(type) {
+	case *Fen:
+		*v, err = ParseFen(value)
+	case *Rate:
+		*v, err = ParseRate(value)
+	case *time.Time:
+		if value != "" {
+			*v, err = time.ParseInLocation(billTimeLayout, value, beijing)
+		}
+	default:
+		if value != "" {
+			err = setIndexedField(field, value)
+		}
+	}
+	return err
+}
+
+// 逐行解析明细在前、统计数据在后的账单，statisticsHeader为统计数据表头的第一列。
+// 每一行解码到newEntry或newStatistics返回的结构体后调用onEntry或onStatistics
+func parseRecords(r io.Reader, statisticsHeader string,
+	newEntry, newStatistics func() interface{},
+	onEntry, onStatistics func(v interface{}) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var entryColumns, statisticsColumns *columnMapper
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			globalLogger.printf("csv Read err: %v", err)
+			return err
+		}
+		for i := range record {
+			record[i] = strings.TrimPrefix(record[i], "`")
+		}
+
+		switch {
+		case entryColumns == nil:
+			entryColumns = newColumnMapper(reflect.TypeOf(newEntry()).Elem(), record)
+		case statisticsColumns == nil && record[0] == statisticsHeader:
+			statisticsColumns = newColumnMapper(reflect.TypeOf(newStatistics()).Elem(), record)
+		case statisticsColumns != nil:
+			s := newStatistics()
+			if err := statisticsColumns.decode(row, record, s); err != nil {
+				return err
+			}
+			if err := onStatistics(s); err != nil {
+				return err
+			}
+		default:
+			entry := newEntry()
+			if err := entryColumns.decode(row, record, entry); err != nil {
+				return err
+			}
+			if err := onEntry(entry); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package wxpay
+
+const (
+	CouponTypeCash   = "CASH"    // 充值代金券
+	CouponTypeNoCash = "NO_CASH" // 非充值优惠券
+)
+
+// Coupon 支付使用的代金券
+type Coupon struct {
+	CouponType string `xml:"coupon_type_$n"` // 代金券类型
+	CouponId   string `xml:"coupon_id_$n"`   // 代金券ID
+	CouponFee  int64  `xml:"coupon_fee_$n"`  // 单个代金券支付金额
+}
+
+// RefundCoupon 申请退款返回的代金券退款信息
+type RefundCoupon struct {
+	CouponType      string `xml:"coupon_type_$n"`       // 代金券类型
+	CouponRefundId  string `xml:"coupon_refund_id_$n"`  // 退款代金券ID
+	CouponRefundFee int64  `xml:"coupon_refund_fee_$n"` // 单个代金券退款金额
+}
+
+// RefundDetailCoupon 退款查询返回的第$n笔退款中的代金券退款信息
+type RefundDetailCoupon struct {
+	CouponType      string `xml:"coupon_type_$n_$m"`       // 代金券类型
+	CouponRefundId  string `xml:"coupon_refund_id_$n_$m"`  // 退款代金券ID
+	CouponRefundFee int64  `xml:"coupon_refund_fee_$n_$m"` // 单个代金券退款金额
+}
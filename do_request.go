@@ -6,110 +6,144 @@ import (
 	"encoding/xml"
 	"io/ioutil"
 	"net/http"
-	"reflect"
 	"time"
 )
 
-func (c *Client) request(url string, in interface{}, out interface{}) ([]byte, error) {
-	const (
-		max = 1000 * time.Millisecond
-	)
-	var (
-		tempDelay time.Duration
-		tryNum    = 0
-		err       error
-		body      []byte
-	)
-	tryLoop:
-	for {
-		if tempDelay == 0 {
-			tempDelay = 100 * time.Millisecond
-		} else {
-			tempDelay *= 2
+func (c *Client) request(ctx context.Context, path string, in interface{}, out interface{}) ([]byte, error) {
+	var body []byte
+	err := c.retry(ctx, path, func() (string, error) {
+		var err error
+		body, err = c.doRequest(ctx, path, in, out)
+		if err != nil {
+			return "", err
 		}
-		if tempDelay > max {
-			tempDelay = max
-		}
-		body, err = c.doRequest(url, in, out)
-		tryNum++
-		if tryNum > 3 {
-			return body, err
-		}
-		switch {
-		case shouldRetry(err):
-			notifyAsync("doRequest err: ", err)
-			time.Sleep(tempDelay)
-			continue tryLoop
-		default:
-			for i := true; i; i = false {
-				if out == nil {
-					break
-				}
-				ot := reflect.TypeOf(out)
-				if ot.Kind() != reflect.Ptr && ot.Kind() != reflect.Interface {
-					break
-				}
-				value := reflect.ValueOf(out).Elem()
-				if reflect.TypeOf(value).Kind() != reflect.Struct {
-					break
-				}
-				metaItf := value.FieldByName("Meta").Interface()
-				if m, ok := metaItf.(Meta); ok {
-					if m.IsSystemErr() ||
-						m.IsBizerrNeedRetry() {
-						notifyAsync("doRequest err: ", m.ErrCode)
-						time.Sleep(tempDelay)
-						continue tryLoop
-					}
-				}
-			}
-			return body, err
+		if m, ok := out.(interface{ meta() *Meta }); ok {
+			return m.meta().ErrCode, nil
 		}
+		return "", nil
+	})
+	if err != nil {
+		return body, err
+	}
+	if m, ok := out.(interface{ meta() *Meta }); ok && c.businessError {
+		return body, m.meta().Err()
 	}
+	return body, nil
 }
 
-func (c *Client) doRequest(url string, in interface{}, out interface{}) ([]byte, error) {
-	body, err := xml.Marshal(in)
+func (c *Client) doRequest(ctx context.Context, path string, in interface{}, out interface{}) ([]byte, error) {
+	body, err := c.post(ctx, path, in)
 	if err != nil {
-		globalLogger.printf("%s xml marshal err: %s", url, err.Error())
 		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer([]byte(body)))
+
+	if err := xml.Unmarshal(body, &out); err != nil {
+		globalLogger.printf("unmarshal body err: %s, body: %s", err.Error(), string(body))
+		return nil, err
+	}
+
+	// return_code不为SUCCESS时微信不签名，由request按WithBusinessError返回*APIError或原始响应
+	var meta Meta
+	if err := xml.Unmarshal(body, &meta); err == nil && meta.ReturnCode != success {
+		return body, nil
+	}
+	if !signedResponse(path) && !hasSign(body) {
+		return body, nil
+	}
+	key, err := c.signKey(ctx)
 	if err != nil {
-		globalLogger.printf("%s new request err: %s", url, err.Error())
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	if err := checkSign(body, key, signTypeOf(in)); err != nil {
+		globalLogger.printf("checkSign err: %s", err.Error())
+		return nil, err
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Second)
-	defer cancel()
-	req = req.WithContext(ctx)
+	return body, nil
+}
 
-	globalLogger.printf("%s %s %s", req.Method, req.URL.String(), string(body))
+// 这些接口的响应没有签名，响应中带有sign时仍然校验
+func signedResponse(path string) bool {
+	switch path {
+	case transferURL, transferInfoURL, getPublicKeyUrl:
+		return false
+	default:
+		return true
+	}
+}
 
-	resp, err := selectedClient(url).Do(req)
+func hasSign(body []byte) bool {
+	m := make(Map)
+	return xml.Unmarshal(body, &m) == nil && m["sign"] != ""
+}
+
+// 发送xml请求并返回原始的响应内容
+func (c *Client) post(ctx context.Context, path string, in interface{}) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := c.send(ctx, path, in)
 	if err != nil {
-		globalLogger.printf("%s %s do err: %s", req.Method, req.URL.String(), err.Error())
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, err = ioutil.ReadAll(resp.Body)
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		globalLogger.printf("%s %s read resp body err: %s", req.Method, req.URL.String(), err.Error())
+		globalLogger.printf("%s %s read resp body err: %s", resp.Request.Method, resp.Request.URL.String(), err.Error())
 		return nil, err
 	}
-	globalLogger.printf("%s %s %s", req.Method, req.URL.String(), string(body))
+	globalLogger.printf("%s %s %s", resp.Request.Method, resp.Request.URL.String(), string(body))
+	return body, nil
+}
 
-	if err := xml.Unmarshal(body, &out); err != nil {
-		globalLogger.printf("unmarshal body err: %s, body: %s", err.Error(), string(body))
+// 发送xml请求，调用方负责读取并关闭resp.Body
+func (c *Client) send(ctx context.Context, path string, in interface{}) (*http.Response, error) {
+	url := c.url(path)
+	body, err := xml.Marshal(in)
+	if err != nil {
+		globalLogger.printf("%s xml marshal err: %s", url, err.Error())
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer([]byte(body)))
+	if err != nil {
+		globalLogger.printf("%s new request err: %s", url, err.Error())
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req = req.WithContext(ctx)
 
-	if err := checkSign(body, c.apiKey); err != nil {
-		globalLogger.printf("checkSign err: %s", err.Error())
+	globalLogger.printf("%s %s %s", req.Method, req.URL.String(), string(body))
+
+	client, err := c.selectedClient(path)
+	if err != nil {
 		return nil, err
 	}
+	if path == downloadBillUrl || path == downloadFundFlowUrl {
+		// 账单是边下载边解析的，耗时取决于账单大小和调用方的处理速度，只由ctx控制
+		noTimeout := *client
+		noTimeout.Timeout = 0
+		client = &noTimeout
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		globalLogger.printf("%s %s do err: %s", req.Method, req.URL.String(), err.Error())
+		return nil, err
+	}
+	return resp, nil
+}
 
-	return body, nil
+// 重试前等待，ctx被取消时立即返回
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
+
+// 单次请求的超时时间，下载账单不受此限制，由ctx控制，DownloadBill等不带ctx的接口使用downloadTimeout
+const requestTimeout = 6 * time.Second
@@ -0,0 +1,65 @@
+package wxpay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_RequestContextDeadline(t *testing.T) {
+	// 第一次请求立即返回SYSTEMERROR，ctx在重试等待期间超时
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var calls int
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		calls++
+		return Map{
+			"return_code": "SUCCESS",
+			"result_code": "FAIL",
+			"err_code":    "SYSTEMERROR",
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.OrderQueryContext(ctx, &OrderQueryRequest{OutTradeNo: "1415757673"}); err != context.DeadlineExceeded {
+		t.Errorf("expect %v, got %v", context.DeadlineExceeded, err)
+	}
+	if calls != 1 {
+		t.Errorf("retried %d times after cancel", calls-1)
+	}
+}
+
+func TestClient_RequestReturnCodeFail(t *testing.T) {
+	// return_code为FAIL的响应没有签名
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<xml><return_code>FAIL</return_code><return_msg>签名错误</return_msg></xml>"))
+	}))
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := c.OrderQuery(&OrderQueryRequest{OutTradeNo: "1415757673"})
+	if err != nil || response.ReturnCode != "FAIL" || response.ReturnMsg != "签名错误" {
+		t.Errorf("response: %+v, err: %v", response, err)
+	}
+
+	c, err = NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithBusinessError())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.OrderQuery(&OrderQueryRequest{OutTradeNo: "1415757673"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ReturnCode != "FAIL" ||
+		err.Error() != "wxpay: return_code=FAIL return_msg=签名错误" {
+		t.Errorf("err: %v", err)
+	}
+}
@@ -1,21 +1,20 @@
 package wxpay
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"context"
-	"encoding/csv"
 	"encoding/xml"
 	"errors"
 	"io"
 	"io/ioutil"
-	"net/http"
 	"strings"
 	"time"
 )
 
 const (
-	downloadBillUrl = "https://api.mch.weixin.qq.com/pay/downloadbill"
+	downloadBillUrl = "/pay/downloadbill"
 )
 
 const (
@@ -30,6 +29,8 @@ type DownloadBillRequest struct {
 	XMLName  xml.Name `xml:"xml"`
 	AppId    string   `xml:"appid,omitempty"`
 	MchId    string   `xml:"mch_id,omitempty"`
+	SubAppId string   `xml:"sub_appid,omitempty"`
+	SubMchId string   `xml:"sub_mch_id,omitempty"`
 	NonceStr string   `xml:"nonce_str,omitempty"`
 	Sign     string   `xml:"sign,omitempty"`
 	SignType string   `xml:"sign_type,omitempty"`
@@ -44,232 +45,283 @@ type DownloadBillResponse struct {
 	Bill       string
 }
 
+// 不带ctx的下载接口的总超时时间，包括重试
+const downloadTimeout = 60 * time.Second
+
 func (c *Client) DownloadBill(request *DownloadBillRequest) (*DownloadBillResponse, error) {
-	const (
-		max = 1000 * time.Millisecond
-	)
-	var (
-		tempDelay time.Duration
-		tryNum    = 0
-		buf       bytes.Buffer
-	)
+	ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancel()
+	return c.DownloadBillContext(ctx, request)
+}
 
-tryLoop:
-	for {
+func (c *Client) DownloadBillContext(ctx context.Context, request *DownloadBillRequest) (*DownloadBillResponse, error) {
+	if err := c.prepareDownloadBill(ctx, request); err != nil {
+		return nil, err
+	}
 
-		if tryNum >= 3 {
-			break tryLoop
-		}
+	bill, err := c.download(ctx, downloadBillUrl, request)
+	if err != nil {
+		return nil, err
+	}
 
-		if tempDelay == 0 {
-			tempDelay = 100 * time.Millisecond
-		} else {
-			tempDelay *= 2
-		}
-		if tempDelay > max {
-			tempDelay = max
-		}
+	var response DownloadBillResponse
 
-		request.MchId = c.mchId
-		request.NonceStr = nonceStr()
-		request.TarType = "GZIP"
-		request.Sign = signStruct(request, c.apiKey)
+	response.Bill = strings.Replace(string(bill), "`", "", -1)
+	response.EntryList = make([]*BillEntry, 0)
+	response.Statistics, err = ParseBill(bytes.NewReader(bill), func(entry *BillEntry) error {
+		response.EntryList = append(response.EntryList, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		body, err := xml.Marshal(&request)
-		if err != nil {
-			globalLogger.printf("Marshal err: %v", err)
-			return nil, err
-		}
+	return &response, nil
+}
 
-		req, err := http.NewRequest(http.MethodPost, downloadBillUrl, bytes.NewBuffer(body))
-		if err != nil {
-			globalLogger.printf("NewRequest err: %v", err)
-			return nil, err
-		}
+// DownloadBillStream 边下载边解析对账单，不会把整个账单读入内存。
+// 每条订单明细调用一次fn，fn返回error时停止解析并返回该error，解析完成后返回账单末尾的统计数据。
+// 开始解析后出错不会重试，避免fn收到重复的明细
+func (c *Client) DownloadBillStream(ctx context.Context, request *DownloadBillRequest, fn func(entry *BillEntry) error) ([]*BillStatistics, error) {
+	if err := c.prepareDownloadBill(ctx, request); err != nil {
+		return nil, err
+	}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		req = req.WithContext(ctx)
-
-		resp, err := selectedClient(downloadBillUrl).Do(req)
-		tryNum++
-		switch {
-		case err != nil:
-			if shouldRetry(err) {
-				notifyAsync("downloadbill err: ", err)
-				time.Sleep(tempDelay)
-				continue tryLoop
-			}
-			globalLogger.printf("do err: %v", err)
-			return nil, err
-		default:
-			defer resp.Body.Close()
-
-			responseBytes, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				globalLogger.printf("do err: %v", err)
-				return nil, err
-			}
-
-			switch request.TarType {
-			case "GZIP":
-				reader, err := gzip.NewReader(bytes.NewBuffer(responseBytes))
-				switch err {
-				case nil:
-					defer reader.Close()
-					if _, err := io.Copy(&buf, reader); err != nil {
-						globalLogger.printf("Copy err: %v", err)
-						return nil, err
-					}
-				case gzip.ErrHeader:
-					buf = *bytes.NewBuffer(responseBytes)
-				default:
-					globalLogger.printf("NewReader err: %v", err)
-					return nil, err
-				}
-			default:
-				buf = *bytes.NewBuffer(responseBytes)
-			}
-
-			var response struct {
-				ReturnCode string `xml:"return_code"`
-				ReturnMsg  string `xml:"return_msg"`
-			}
-			if err = xml.Unmarshal(buf.Bytes(), &response); err == nil {
-				switch response.ReturnMsg {
-				case billNoExistErr.Error():
-					return nil, billNoExistErr
-				case "SYSTEMERROR",
-					"CompressGZip Error",
-					"UnCompressGZip Error":
-					notifyAsync("downloadbill err: ", err)
-					time.Sleep(tempDelay)
-					continue tryLoop
-				default:
-					return nil, errors.New(response.ReturnMsg)
-				}
-			} else {
-				break tryLoop
-			}
-		}
+	body, err := c.downloadStream(ctx, downloadBillUrl, request)
+	if err != nil {
+		return nil, err
 	}
+	defer body.Close()
 
-	var response DownloadBillResponse
+	return ParseBill(body, fn)
+}
 
-	response.Bill = buf.String()
-	response.Bill = strings.Replace(response.Bill, "`", "", -1)
-	pos := strings.LastIndex(response.Bill, "%")
+func (c *Client) prepareDownloadBill(ctx context.Context, request *DownloadBillRequest) error {
+	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
+	request.NonceStr = nonceStr()
+	request.TarType = "GZIP"
+	var err error
+	request.Sign, err = c.signStruct(ctx, request)
+	return err
+}
 
-	firstPart := response.Bill[0 : pos+1]
-	secondPart := response.Bill[pos+1:]
+const (
+	BillTradeStatusSuccess = "SUCCESS"
+	BillTradeStatusRefund  = "REFUND"
+	BillTradeStatusRevoked = "REVOKED"
+)
+
+// 不同类型的账单列不完全相同，csv tag中用|分隔同一字段的不同列名，
+// 账单中没有的列对应的字段为空，没有对应字段的列保存在Extra中。
+// 金额精确转换为分，时间为北京时间，格式不对时返回带行号的错误
+type BillStatistics struct {
+	TradeOrderCount          int64             `csv:"总交易单数" json:"trade_order_count"`
+	TotalBusinessTransaction Fen               `csv:"应结订单总金额|总交易额" json:"total_business_transaction"`
+	TotalRefundFee           Fen               `csv:"退款总金额|总退款金额" json:"total_refund_fee"`
+	TotalCouponFee           Fen               `csv:"充值券退款总金额|总企业红包退款金额" json:"total_coupon_fee"`
+	TotalHandlingCharge      Fen               `csv:"手续费总金额" json:"total_handling_charge"`
+	TotalOrderFee            Fen               `csv:"订单总金额" json:"total_order_fee"`
+	TotalApplyRefundFee      Fen               `csv:"申请退款总金额" json:"total_apply_refund_fee"`
+	Extra                    map[string]string `csv:"-" json:"extra,omitempty"`
+}
 
-	orderList, err := csv.NewReader(strings.NewReader(firstPart)).ReadAll()
+type BillEntry struct {
+	TimeEnd           time.Time         `csv:"交易时间" json:"time_end"`
+	AppId             string            `csv:"公众账号ID" json:"appid"`
+	MchId             string            `csv:"商户号" json:"mch_id"`
+	SubMchId          string            `csv:"特约商户号|子商户号" json:"sub_mch_id"`
+	DeviceInfo        string            `csv:"设备号" json:"device_info"`
+	TransactionId     string            `csv:"微信订单号" json:"transaction_id"`
+	OutTradeNo        string            `csv:"商户订单号" json:"out_trade_no"`
+	OpenId            string            `csv:"用户标识" json:"openid"`
+	TradeType         string            `csv:"交易类型" json:"trade_type"`
+	TradeStatus       string            `csv:"交易状态" json:"trade_status"`
+	BankType          string            `csv:"付款银行" json:"bank_type"`
+	FeeType           string            `csv:"货币种类" json:"fee_type"`
+	TotalFee          Fen               `csv:"应结订单金额|总金额" json:"total_fee"`
+	CouponFee         Fen               `csv:"代金券金额|企业红包金额" json:"coupon_fee"`
+	RefundApplyTime   time.Time         `csv:"退款申请时间" json:"refund_apply_time"`
+	RefundSuccessTime time.Time         `csv:"退款成功时间" json:"refund_success_time"`
+	RefundId          string            `csv:"微信退款单号" json:"refund_id"`
+	OutRefundNo       string            `csv:"商户退款单号" json:"out_refund_no"`
+	RefundFee         Fen               `csv:"退款金额" json:"refund_fee"`
+	CouponRefundFee   Fen               `csv:"充值券退款金额|企业红包退款金额" json:"coupon_refund_fee"`
+	RefundChannel     string            `csv:"退款类型" json:"refund_channel"`
+	RefundStatus      string            `csv:"退款状态" json:"refund_status"`
+	Body              string            `csv:"商品名称" json:"body"`
+	Attach            string            `csv:"商户数据包" json:"attach"`
+	HandlingCharge    Fen               `csv:"手续费" json:"handling_charge"`
+	Rate              Rate              `csv:"费率" json:"rate"`
+	OrderFee          Fen               `csv:"订单金额" json:"order_fee"`
+	ApplyRefundFee    Fen               `csv:"申请退款金额" json:"apply_refund_fee"`
+	RateRemark        string            `csv:"费率备注" json:"rate_remark"`
+	Extra             map[string]string `csv:"-" json:"extra,omitempty"`
+}
+
+// 下载账单并解压，按RetryPolicy重试
+func (c *Client) download(ctx context.Context, path string, request interface{}) ([]byte, error) {
+	body, err := c.downloadStream(ctx, path, request)
 	if err != nil {
-		globalLogger.printf("csv.NewReader err: %v", err)
 		return nil, err
 	}
+	defer body.Close()
 
-	response.EntryList = make([]*BillEntry, 0)
+	bill, err := ioutil.ReadAll(body)
+	if err != nil {
+		globalLogger.printf("read bill err: %v", err)
+		return nil, err
+	}
+	return bill, nil
+}
 
-	if len(orderList) >= 2 {
-		for _, order := range orderList[1:] {
-			if len(order) == 24 {
-				entry := new(BillEntry)
-				response.EntryList = append(response.EntryList, entry)
-
-				entry.TimeEnd = order[0]
-				entry.AppId = order[1]
-				entry.MchId = order[2]
-				entry.SubMchId = order[3]
-				entry.DeviceInfo = order[4]
-				entry.TransactionId = order[5]
-				entry.OutTradeNo = order[6]
-				entry.OpenId = order[7]
-				entry.TradeType = order[8]
-				entry.TradeStatus = order[9]
-				entry.BankType = order[10]
-				entry.FeeType = order[11]
-				entry.TotalFee = order[12]
-				entry.CouponFee = order[13]
-				entry.RefundId = order[14]
-				entry.OutRefundNo = order[15]
-				entry.RefundFee = order[16]
-				entry.CouponRefundFee = order[17]
-				entry.RefundChannel = order[18]
-				entry.RefundStatus = order[19]
-				entry.Body = order[20]
-				entry.Attach = order[21]
-				entry.HandlingCharge = order[22]
-				entry.Rate = order[23]
-			}
-		}
+// 打开解压后的账单内容，按RetryPolicy重试，调用方负责关闭
+func (c *Client) downloadStream(ctx context.Context, path string, request interface{}) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := c.retry(ctx, path, func() (string, error) {
+		var (
+			errCode string
+			err     error
+		)
+		body, errCode, err = c.openBill(ctx, path, request)
+		return errCode, err
+	})
+	return body, err
+}
+
+// 下载gzip压缩的原始账单，按RetryPolicy重试，微信返回未压缩的账单时压缩后返回
+func (c *Client) downloadGzip(ctx context.Context, path string, request interface{}) ([]byte, error) {
+	var raw []byte
+	err := c.retry(ctx, path, func() (string, error) {
+		var (
+			errCode string
+			err     error
+		)
+		raw, errCode, err = c.downloadGzipOnce(ctx, path, request)
+		return errCode, err
+	})
+	return raw, err
+}
+
+func (c *Client) downloadGzipOnce(ctx context.Context, path string, request interface{}) ([]byte, string, error) {
+	resp, err := c.send(ctx, path, request)
+	if err != nil {
+		return nil, "", err
+	}
+	raw, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, "", err
 	}
 
-	statisticsList, err := csv.NewReader(strings.NewReader(secondPart)).ReadAll()
+	// 完整解压一次，确认账单没有损坏
+	bill, errCode, err := decodeBill(path, ioutil.NopCloser(bytes.NewReader(raw)))
 	if err != nil {
-		globalLogger.printf("csv.NewReader err: %v", err)
-		return nil, err
+		return nil, errCode, err
+	}
+	defer bill.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, bill); err != nil {
+		globalLogger.printf("read bill err: %v", err)
+		return nil, errCodeSystemError, err
+	}
+	if bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+		return raw, "", nil
 	}
 
-	response.Statistics = make([]*BillStatistics, 0)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(buf.Bytes())
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+	return gz.Bytes(), "", nil
+}
 
-	if len(statisticsList) >= 2 {
-		for _, statistics := range statisticsList[1:] {
-			if len(statistics) == 5 {
-				s := new(BillStatistics)
-				response.Statistics = append(response.Statistics, s)
+// 解压后的账单内容，关闭时关闭http响应
+type billBody struct {
+	io.Reader
+	io.Closer
+}
 
-				s.TradeOrderCount = statistics[0]
-				s.TotalBusinessTransaction = statistics[1]
-				s.TotalRefundFee = statistics[2]
+func (c *Client) openBill(ctx context.Context, path string, request interface{}) (io.ReadCloser, string, error) {
+	resp, err := c.send(ctx, path, request)
+	if err != nil {
+		return nil, "", err
+	}
+	return decodeBill(path, resp.Body)
+}
 
-				s.TotalCouponFee = statistics[3]
-				s.TotalHandlingCharge = statistics[4]
-			}
+// 解压账单并识别微信返回的错误信息，body在返回错误时被关闭
+func decodeBill(path string, body io.ReadCloser) (io.ReadCloser, string, error) {
+	buffered := bufio.NewReader(body)
+	var reader io.Reader = buffered
+	// 成功时返回gzip压缩的账单，失败时返回未压缩的xml
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			body.Close()
+			globalLogger.printf("NewReader err: %v", err)
+			return nil, "", err
 		}
+		reader = gz
 	}
 
-	return &response, nil
-
-}
+	bill := bufio.NewReader(reader)
+	head, err := bill.Peek(64)
+	if err != nil && err != io.EOF {
+		body.Close()
+		globalLogger.printf("read bill err: %v", err)
+		return nil, "", err
+	}
+	if !bytes.HasPrefix(bytes.TrimSpace(head), []byte("<xml")) {
+		return &billBody{Reader: bill, Closer: body}, "", nil
+	}
 
-const (
-	BillTradeStatusSuccess = "SUCCESS"
-	BillTradeStatusRefund  = "REFUND"
-	BillTradeStatusRevoked = "REVOKED"
-)
+	defer body.Close()
+	data, err := ioutil.ReadAll(bill)
+	if err != nil {
+		return nil, "", err
+	}
+	globalLogger.printf("%s %s", path, string(data))
 
-type BillStatistics struct {
-	TradeOrderCount          string `csv:"总交易单数"`
-	TotalBusinessTransaction string `csv:"总交易额"`
-	TotalRefundFee           string `csv:"总退款金额"`
-	TotalCouponFee           string `csv:"总企业红包退款金额"`
-	TotalHandlingCharge      string `csv:"手续费总金额"`
+	var response struct {
+		ReturnCode string `xml:"return_code"`
+		ReturnMsg  string `xml:"return_msg"`
+	}
+	if err := xml.Unmarshal(data, &response); err != nil {
+		return nil, "", err
+	}
+	switch response.ReturnMsg {
+	case billNoExistErr.Error():
+		return nil, "", billNoExistErr
+	case "SYSTEMERROR",
+		"CompressGZip Error",
+		"UnCompressGZip Error":
+		return nil, errCodeSystemError, errors.New(response.ReturnMsg)
+	default:
+		return nil, "", errors.New(response.ReturnMsg)
+	}
 }
 
-type BillEntry struct {
-	TimeEnd         string `csv:"交易时间"`
-	AppId           string `csv:"公众账号ID"`
-	MchId           string `csv:"商户号"`
-	SubMchId        string `csv:"子商户号"`
-	DeviceInfo      string `csv:"设备号"`
-	TransactionId   string `csv:"微信订单号"`
-	OutTradeNo      string `csv:"商户订单号"`
-	OpenId          string `csv:"用户标识"`
-	TradeType       string `csv:"交易类型"`
-	TradeStatus     string `csv:"交易状态"`
-	BankType        string `csv:"付款银行"`
-	FeeType         string `csv:"货币种类"`
-	TotalFee        string `csv:"总金额"`
-	CouponFee       string `csv:"企业红包金额"`
-	RefundId        string `csv:"微信退款单号"`
-	OutRefundNo     string `csv:"商户退款单号"`
-	RefundFee       string `csv:"退款金额"`
-	CouponRefundFee string `csv:"企业红包退款金额"`
-	RefundChannel   string `csv:"退款类型"`
-	RefundStatus    string `csv:"退款状态"`
-	Body            string `csv:"商品名称"`
-	Attach          string `csv:"商户数据包"`
-	HandlingCharge  string `csv:"手续费"`
-	Rate            string `csv:"费率"`
+// 统计数据的第一列表头，用于区分订单明细和统计数据
+const billStatisticsHeader = "总交易单数"
+
+// ParseBill 逐行解析解压后的对账单，每条订单明细调用一次fn，返回账单末尾的统计数据。
+// 按表头把列映射到字段，对账单的每个字段都以`开头，解析时去掉
+func ParseBill(r io.Reader, fn func(entry *BillEntry) error) ([]*BillStatistics, error) {
+	statistics := make([]*BillStatistics, 0)
+	err := parseRecords(r, billStatisticsHeader,
+		func() interface{} { return new(BillEntry) },
+		func() interface{} { return new(BillStatistics) },
+		func(v interface{}) error { return fn(v.(*BillEntry)) },
+		func(v interface{}) error {
+			statistics = append(statistics, v.(*BillStatistics))
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return statistics, nil
 }
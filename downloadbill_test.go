@@ -0,0 +1,152 @@
+package wxpay
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const allBill = "交易时间,公众账号ID,商户号,子商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,总金额,企业红包金额,微信退款单号,商户退款单号,退款金额,企业红包退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率\r\n" +
+	"`2014-11-10 16:33:45,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1001690740201411100005734289,`1415640626,`085e9858e3ba5186aafcbaed1,`MICROPAY,`SUCCESS,`OTHERS,`CNY,`0.01,`0.0,`0,`0,`0,`0,`,`,`被扫支付测试,`订单额外描述,`0,`0.60%\r\n" +
+	"`2014-11-10 16:46:14,`wx2421b1c4370ec43b,`10000100,`0,`1000,`1002780740201411100005729794,`1415635270,`085e9858e90ca40c0b5aee463,`MICROPAY,`SUCCESS,`OTHERS,`CNY,`0.01,`0.0,`0,`0,`0,`0,`,`,`被扫支付\"测试\",`订单额外描述,`0,`0.60%\r\n" +
+	"总交易单数,总交易额,总退款金额,总企业红包退款金额,手续费总金额\r\n" +
+	"`2,`0.02,`0.0,`0.0,`0\r\n"
+
+func TestClient_DownloadBill(t *testing.T) {
+	server := newBillServer(t, func(path string, req Map) string {
+		if path != downloadBillUrl || req["tar_type"] != "GZIP" || req["sign"] != sign(req, testApiKey, "") {
+			t.Errorf("request: %s %v", path, req)
+		}
+		return allBill
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "10000100", WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.DownloadBill(&DownloadBillRequest{BillDate: "20141110", BillType: BillTypeAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.EntryList) != 2 || len(resp.Statistics) != 1 {
+		t.Fatalf("entries: %d, statistics: %d", len(resp.EntryList), len(resp.Statistics))
+	}
+	if e := resp.EntryList[1]; !e.TimeEnd.Equal(time.Date(2014, 11, 10, 16, 46, 14, 0, beijing)) || e.OutTradeNo != "1415635270" ||
+		e.Body != `被扫支付"测试"` || e.TotalFee != 1 || e.Rate != 6000 {
+		t.Errorf("entry: %+v", e)
+	}
+	if s := resp.Statistics[0]; s.TradeOrderCount != 2 || s.TotalBusinessTransaction != 2 {
+		t.Errorf("statistics: %+v", s)
+	}
+}
+
+func TestClient_DownloadBillStream(t *testing.T) {
+	server := newBillServer(t, func(path string, req Map) string {
+		return allBill
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "10000100", WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var outTradeNos []string
+	statistics, err := c.DownloadBillStream(context.Background(), &DownloadBillRequest{BillDate: "20141110"}, func(entry *BillEntry) error {
+		outTradeNos = append(outTradeNos, entry.OutTradeNo)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outTradeNos) != 2 || outTradeNos[0] != "1415640626" || outTradeNos[1] != "1415635270" {
+		t.Errorf("entries: %v", outTradeNos)
+	}
+	if len(statistics) != 1 || statistics[0].TradeOrderCount != 2 {
+		t.Errorf("statistics: %+v", statistics)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+	_, err = c.DownloadBillStream(context.Background(), &DownloadBillRequest{BillDate: "20141110"}, func(entry *BillEntry) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("err: %v, count: %d", err, count)
+	}
+}
+
+func TestClient_DownloadBillNoExist(t *testing.T) {
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		return Map{
+			"return_code": "FAIL",
+			"return_msg":  "No Bill Exist",
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "10000100", WithBaseUrl(server.URL), WithRetryPolicy(NoRetry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.DownloadBillStream(context.Background(), &DownloadBillRequest{BillDate: "20141110"}, func(entry *BillEntry) error {
+		t.Error("unexpected entry")
+		return nil
+	})
+	if !IsBillNoExist(err) {
+		t.Errorf("err: %v", err)
+	}
+}
+
+const refundBill = "交易时间,公众账号ID,商户号,特约商户号,设备号,微信订单号,商户订单号,用户标识,交易类型,交易状态,付款银行,货币种类,应结订单金额,代金券金额,退款申请时间,退款成功时间,微信退款单号,商户退款单号,退款金额,充值券退款金额,退款类型,退款状态,商品名称,商户数据包,手续费,费率,订单金额,申请退款金额,费率备注,新增列\r\n" +
+	"`2018-05-02 10:28:33,`wx2421b1c4370ec43b,`10000100,`1900000109,`,`4200000118201805021234567890,`20180502102833,`oUpF8uMuAJO_M2pxb1Q9zNjWeS6o,`JSAPI,`REFUND,`CMB_CREDIT,`CNY,`0.00,`0.00,`2018-05-03 09:01:02,`2018-05-03 09:01:05,`50000306542018050304599386478,`R20180503090102,`1.00,`0.00,`ORIGINAL,`SUCCESS,`测试商品,`,`-0.01,`0.60%,`0.00,`1.00,`,`x\r\n" +
+	"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额,订单总金额,申请退款总金额\r\n" +
+	"`1,`0.00,`1.00,`0.00,`-0.01,`0.00,`1.00\r\n"
+
+func TestParseBill_Refund(t *testing.T) {
+	var entries []*BillEntry
+	statistics, err := ParseBill(strings.NewReader(refundBill), func(entry *BillEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || len(statistics) != 1 {
+		t.Fatalf("entries: %d, statistics: %d", len(entries), len(statistics))
+	}
+	e := entries[0]
+	if e.SubMchId != "1900000109" || e.RefundApplyTime.Format(billTimeLayout) != "2018-05-03 09:01:02" || e.RefundSuccessTime.Sub(e.RefundApplyTime) != 3*time.Second ||
+		e.RefundFee != 100 || e.ApplyRefundFee != 100 || e.HandlingCharge != -1 || e.Extra["新增列"] != "x" {
+		t.Errorf("entry: %+v", e)
+	}
+	if s := statistics[0]; s.TotalRefundFee != 100 || s.TotalApplyRefundFee != 100 || s.TotalHandlingCharge != -1 {
+		t.Errorf("statistics: %+v", s)
+	}
+}
+
+func TestParseBill_ColumnMismatch(t *testing.T) {
+	bill := "交易时间,公众账号ID\r\n`2018-05-02 10:28:33\r\n"
+	_, err := ParseBill(strings.NewReader(bill), func(entry *BillEntry) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("err: %v", err)
+	}
+}
+
+func TestParseBill_InvalidAmount(t *testing.T) {
+	bill := "交易时间,应结订单金额\r\n`2018-05-02 10:28:33,`0.01\r\n`2018-05-02 10:28:34,`0.015\r\n"
+	count := 0
+	_, err := ParseBill(strings.NewReader(bill), func(entry *BillEntry) error {
+		count++
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "row 3 column 应结订单金额") || count != 1 {
+		t.Errorf("err: %v, count: %d", err, count)
+	}
+}
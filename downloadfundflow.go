@@ -0,0 +1,159 @@
+package wxpay
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=9_18&index=7
+
+const (
+	downloadFundFlowUrl = "/pay/downloadfundflow"
+)
+
+const (
+	AccountTypeBasic     = "Basic"     // 基本账户
+	AccountTypeOperation = "Operation" // 运营账户
+	AccountTypeFees      = "Fees"      // 手续费账户
+)
+
+// 只支持HMAC-SHA256签名，需要双向证书
+type DownloadFundFlowRequest struct {
+	XMLName     xml.Name `xml:"xml"`
+	AppId       string   `xml:"appid,omitempty"`
+	MchId       string   `xml:"mch_id,omitempty"`
+	NonceStr    string   `xml:"nonce_str,omitempty"`
+	Sign        string   `xml:"sign,omitempty"`
+	SignType    string   `xml:"sign_type,omitempty"`
+	BillDate    string   `xml:"bill_date,omitempty"`
+	AccountType string   `xml:"account_type,omitempty"`
+	TarType     string   `xml:"tar_type,omitempty"`
+}
+
+type DownloadFundFlowResponse struct {
+	EntryList  []*FundFlowEntry
+	Statistics []*FundFlowStatistics
+	Bill       string
+}
+
+func (c *Client) DownloadFundFlow(request *DownloadFundFlowRequest) (*DownloadFundFlowResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+	defer cancel()
+	return c.DownloadFundFlowContext(ctx, request)
+}
+
+func (c *Client) DownloadFundFlowContext(ctx context.Context, request *DownloadFundFlowRequest) (*DownloadFundFlowResponse, error) {
+	if err := c.prepareDownloadFundFlow(ctx, request); err != nil {
+		return nil, err
+	}
+
+	bill, err := c.download(ctx, downloadFundFlowUrl, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var response DownloadFundFlowResponse
+
+	response.Bill = strings.Replace(string(bill), "`", "", -1)
+	response.EntryList = make([]*FundFlowEntry, 0)
+	response.Statistics, err = ParseFundFlow(bytes.NewReader(bill), func(entry *FundFlowEntry) error {
+		response.EntryList = append(response.EntryList, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+// DownloadFundFlowStream 边下载边解析资金账单，每条流水调用一次fn，解析完成后返回汇总数据
+func (c *Client) DownloadFundFlowStream(ctx context.Context, request *DownloadFundFlowRequest, fn func(entry *FundFlowEntry) error) ([]*FundFlowStatistics, error) {
+	if err := c.prepareDownloadFundFlow(ctx, request); err != nil {
+		return nil, err
+	}
+
+	body, err := c.downloadStream(ctx, downloadFundFlowUrl, request)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ParseFundFlow(body, fn)
+}
+
+func (c *Client) prepareDownloadFundFlow(ctx context.Context, request *DownloadFundFlowRequest) error {
+	request.MchId = c.mchId
+	request.NonceStr = nonceStr()
+	request.SignType = SignTypeHmacSha256
+	request.TarType = "GZIP"
+
+	if len(request.BillDate) == 0 {
+		return errors.New("bill_date is zero")
+	}
+
+	switch request.AccountType {
+	case AccountTypeBasic, AccountTypeOperation, AccountTypeFees:
+	default:
+		return errors.New("wrong account_type")
+	}
+
+	var err error
+	request.Sign, err = c.signStruct(ctx, request)
+	return err
+}
+
+// ParseFundFlow 逐行解析解压后的资金账单，每条流水调用一次fn，返回汇总数据，列与字段的对应规则同ParseBill
+func ParseFundFlow(r io.Reader, fn func(entry *FundFlowEntry) error) ([]*FundFlowStatistics, error) {
+	statistics := make([]*FundFlowStatistics, 0)
+	err := parseRecords(r, fundFlowStatisticsHeader,
+		func() interface{} { return new(FundFlowEntry) },
+		func() interface{} { return new(FundFlowStatistics) },
+		func(v interface{}) error { return fn(v.(*FundFlowEntry)) },
+		func(v interface{}) error {
+			statistics = append(statistics, v.(*FundFlowStatistics))
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return statistics, nil
+}
+
+const (
+	FinancialTypeIncome      = "收入"
+	FinancialTypeExpenditure = "支出"
+)
+
+// 汇总数据的第一列表头，用于区分流水明细和汇总数据
+const fundFlowStatisticsHeader = "资金流水总笔数"
+
+type FundFlowStatistics struct {
+	FlowCount         int64             `csv:"资金流水总笔数" json:"flow_count"`
+	IncomeCount       int64             `csv:"收入笔数" json:"income_count"`
+	IncomeAmount      Fen               `csv:"收入金额" json:"income_amount"`
+	ExpenditureCount  int64             `csv:"支出笔数" json:"expenditure_count"`
+	ExpenditureAmount Fen               `csv:"支出金额" json:"expenditure_amount"`
+	Extra             map[string]string `csv:"-" json:"extra,omitempty"`
+}
+
+type FundFlowEntry struct {
+	BillingTime   time.Time         `csv:"记账时间" json:"billing_time"`
+	TransactionId string            `csv:"微信支付业务单号" json:"transaction_id"`
+	FundFlowId    string            `csv:"资金流水单号" json:"fund_flow_id"`
+	BizName       string            `csv:"业务名称" json:"biz_name"`
+	BizType       string            `csv:"业务类型" json:"biz_type"`
+	FinancialType string            `csv:"收支类型" json:"financial_type"`
+	Amount        Fen               `csv:"收支金额（元）" json:"amount"`
+	Balance       Fen               `csv:"账户结余（元）" json:"balance"`
+	Applicant     string            `csv:"资金变更提交申请人" json:"applicant"`
+	Memo          string            `csv:"备注" json:"memo"`
+	BizVoucherId  string            `csv:"业务凭证号" json:"biz_voucher_id"`
+	Extra         map[string]string `csv:"-" json:"extra,omitempty"`
+}
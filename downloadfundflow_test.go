@@ -0,0 +1,97 @@
+package wxpay
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var fundFlowBill = "记账时间,微信支付业务单号,资金流水单号,业务名称,业务类型,收支类型,收支金额（元）,账户结余（元）,资金变更提交申请人,备注,业务凭证号\r\n" +
+	"`2018-02-01 04:21:23,`50000305742018020103387128253,`1900009231201802015884652186,`退款,`退款,`支出,`0.02,`0.17,`system,`缺货,`REF4200000068201801293084726067\r\n" +
+	"`2018-02-01 04:21:23,`4200000068201801293084726067,`1900009231201802015884652185,`交易,`交易,`收入,`0.19,`0.19,`system,`,`4200000068201801293084726067\r\n" +
+	"资金流水总笔数,收入笔数,收入金额,支出笔数,支出金额\r\n" +
+	"`2,`1,`0.19,`1,`0.02\r\n"
+
+// 返回gzip压缩后的账单
+func newBillServer(t *testing.T, handler func(path string, req Map) string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := make(Map)
+		if err := xml.Unmarshal(body, &req); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(handler(r.URL.Path, req)))
+		zw.Close()
+		w.Write(buf.Bytes())
+	}))
+}
+
+func TestClient_DownloadFundFlow(t *testing.T) {
+	server := newBillServer(t, func(path string, req Map) string {
+		if path != downloadFundFlowUrl || req["sign_type"] != SignTypeHmacSha256 || req["sign"] != sign(req, testApiKey, SignTypeHmacSha256) {
+			t.Errorf("request: %s %v", path, req)
+		}
+		return fundFlowBill
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.DownloadFundFlow(&DownloadFundFlowRequest{BillDate: "20180201", AccountType: AccountTypeBasic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.EntryList) != 2 || len(resp.Statistics) != 1 {
+		t.Fatalf("entries: %d, statistics: %d", len(resp.EntryList), len(resp.Statistics))
+	}
+	if e := resp.EntryList[0]; e.FundFlowId != "1900009231201802015884652186" || e.FinancialType != FinancialTypeExpenditure || e.Amount != 2 || e.Balance != 17 ||
+		e.BillingTime.Format(billTimeLayout) != "2018-02-01 04:21:23" {
+		t.Errorf("entry: %+v", e)
+	}
+	if s := resp.Statistics[0]; s.FlowCount != 2 || s.IncomeCount != 1 || s.ExpenditureAmount != 2 {
+		t.Errorf("statistics: %+v", s)
+	}
+}
+
+func TestClient_DownloadFundFlowStream(t *testing.T) {
+	server := newBillServer(t, func(path string, req Map) string {
+		return fundFlowBill
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total Fen
+	statistics, err := c.DownloadFundFlowStream(context.Background(), &DownloadFundFlowRequest{BillDate: "20180201", AccountType: AccountTypeBasic}, func(entry *FundFlowEntry) error {
+		total += entry.Amount
+		return nil
+	})
+	if err != nil || total != 21 || len(statistics) != 1 {
+		t.Errorf("total: %d, statistics: %v, err: %v", total, statistics, err)
+	}
+}
+
+func TestParseFundFlow_ColumnMismatch(t *testing.T) {
+	bill := "记账时间,微信支付业务单号,资金流水单号\r\n`2018-02-01 04:21:23,`50000305742018020103387128253\r\n"
+	_, err := ParseFundFlow(strings.NewReader(bill), func(entry *FundFlowEntry) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "row 2") {
+		t.Errorf("err: %v", err)
+	}
+}
@@ -4,7 +4,16 @@ type Error string
 
 // 自定义错误
 const (
-	signNotMatchErr = Error("SignNotMatch")
+	signNotMatchErr       = Error("SignNotMatch")
+	signTypeNotSupportErr = Error("SignTypeNotSupport")
+	certNotSetErr         = Error("CertNotSet")
+	microPayNotPaidErr    = Error("MicroPayNotPaid")
+	mchIdNotMatchErr      = Error("MchIdNotMatch")
+	appIdNotMatchErr      = Error("AppIdNotMatch")
+	orderNotFoundErr      = Error("OrderNotFound")
+	totalFeeNotMatchErr   = Error("TotalFeeNotMatch")
+	transferUnknownErr    = Error("TransferUnknown")
+	transferFailedErr     = Error("TransferFailed")
 )
 
 // 微信的错误,请不要修改内容
@@ -12,14 +21,114 @@ const (
 	billNoExistErr = Error("No Bill Exist")
 )
 
+// 微信返回的错误码，可以用errors.Is(err, ErrOrderPaid)判断*APIError
+const (
+	ErrNoAuth               = Error(errCodeNoAuth)
+	ErrNotEnough            = Error(errCodeNotEnough)
+	ErrTradeOverDue         = Error(errCodeTradeOverDue)
+	ErrOrderPaid            = Error(errCodeOrderPaid)
+	ErrOrderClosed          = Error(errCodeOrderClosed)
+	ErrSystemError          = Error(errCodeSystemError)
+	ErrAppidNotExist        = Error(errCodeAppidNotExist)
+	ErrMchidNotExist        = Error(errCodeMchidNotExist)
+	ErrAppidMchidNotMatch   = Error(errCodeAppidMchidNotMatch)
+	ErrLackParams           = Error(errCodeLackParams)
+	ErrOutTradeNoUsed       = Error(errCodeOutTradeNoUsed)
+	ErrSignError            = Error(errCodeSignError)
+	ErrXmlFormatError       = Error(errCodeXmlFormatError)
+	ErrRequirePostMethod    = Error(errCodeRequirePostMethod)
+	ErrPostDataEmpty        = Error(errCodePostDataEmpty)
+	ErrNotUtf8              = Error(errCodeNotUtf8)
+	ErrOrderNotExist        = Error(errCodeOrderNotExist)
+	ErrBizerrNeedRetry      = Error(errCodeBizerrNeedRetry)
+	ErrRefundNotExist       = Error(errCodeRefundNotExist)
+	ErrInvalidRequest       = Error(errCodeInvalidRequest)
+	ErrParamError           = Error(errCodeParamError)
+	ErrFrequencyLimited     = Error(errCodeFrequencyLimited)
+	ErrUserAccountAbnormal  = Error(errCodeUserAccountAbnormal)
+	ErrInvalidTransactionId = Error(errCodeInvalidTransactionId)
+	ErrRefundError          = Error(errCodeRefundError)
+	ErrUserPaying           = Error(errCodeUserPaying)
+	ErrBankError            = Error(errCodeBankError)
+	ErrAuthCodeExpire       = Error(errCodeAuthCodeExpire)
+	ErrAuthCodeError        = Error(errCodeAuthCodeError)
+	ErrAuthCodeInvalid      = Error(errCodeAuthCodeInvalid)
+	ErrSendFailed           = Error(errCodeSendFailed)
+	ErrNotFound             = Error(errCodeNotFound)
+	ErrPayError             = Error(errCodePayError)
+	ErrBuyerMismatch        = Error(errCodeBuyerMismatch)
+	ErrOrderReversed        = Error(errCodeOrderReversed)
+	ErrAmountLimit          = Error(errCodeAmountLimit)
+	ErrOpenidError          = Error(errCodeOpenidError)
+	ErrNameMismatch         = Error(errCodeNameMismatch)
+	ErrV2AccountSimpleBan   = Error(errCodeV2AccountSimpleBan)
+	ErrMoneyLimit           = Error(errCodeMoneyLimit)
+	ErrSendNumLimit         = Error(errCodeSendNumLimit)
+)
+
 func (err Error) Error() string {
 	return string(err)
 }
 
+// APIError 通信失败(return_code=FAIL)或业务失败(result_code=FAIL)时返回的错误
+type APIError struct {
+	ReturnCode string
+	ReturnMsg  string
+	ResultCode string
+	ErrCode    string
+	ErrCodeDes string
+}
+
+func (err *APIError) Error() string {
+	if err.ReturnCode != success {
+		return "wxpay: return_code=" + err.ReturnCode + " return_msg=" + err.ReturnMsg
+	}
+	return "wxpay: err_code=" + err.ErrCode + " err_code_des=" + err.ErrCodeDes
+}
+
+// Is 使errors.Is(err, ErrOrderPaid)按err_code匹配
+func (err *APIError) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && err.ErrCode != "" && string(t) == err.ErrCode
+}
+
+// WithBusinessError 接口返回return_code或result_code为FAIL时返回*APIError，而不是nil
+func WithBusinessError() Option {
+	return func(c *Client) error {
+		c.businessError = true
+		return nil
+	}
+}
+
 func IsBillNoExist(err error) bool {
 	return err == billNoExistErr
 }
 
+// 支付通知与商户系统中的订单不一致，可能是伪造的通知
+func IsOrderNotMatch(err error) bool {
+	switch err {
+	case mchIdNotMatchErr, appIdNotMatchErr, orderNotFoundErr, totalFeeNotMatchErr:
+		return true
+	default:
+		return false
+	}
+}
+
+// 付款码支付没有成功，订单已撤销或关闭
+func IsMicroPayNotPaid(err error) bool {
+	return err == microPayNotPaidErr
+}
+
+// 企业付款的结果仍然未知，之后必须用同一个partner_trade_no查询或重试
+func IsTransferUnknown(err error) bool {
+	return err == transferUnknownErr
+}
+
+// 企业付款已确认失败，查询到的失败原因见TransferInfoResponse.Reason
+func IsTransferFailed(err error) bool {
+	return err == transferFailedErr
+}
+
 func shouldRetry(err error) bool {
 	switch err := err.(type) {
 	case interface {
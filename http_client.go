@@ -3,55 +3,149 @@ package wxpay
 import (
 	"crypto/tls"
 	"encoding/pem"
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/pkcs12"
 )
 
-var client = &http.Client{
-	Timeout: 60 * time.Second,
+const (
+	httpClientTimeout = 60 * time.Second
+)
+
+// SetTlsClient设置的默认双向证书客户端，Client没有设置证书时使用
+var defaultTls struct {
+	mu     sync.Mutex
+	client *http.Client
 }
 
-var tlsClient = &http.Client{
-	Timeout: 60 * time.Second,
+func newHttpClient() *http.Client {
+	return &http.Client{
+		Timeout: httpClientTimeout,
+	}
 }
 
-func selectedClient(url string) *http.Client {
-	switch url {
-	case refundUrl, reverseUrl, transferURL:
-		return tlsClient
+// 需要双向证书的接口使用tlsClient
+func (c *Client) selectedClient(path string) (*http.Client, error) {
+	switch path {
+	case refundUrl, reverseUrl, transferURL, transferInfoURL, downloadFundFlowUrl,
+		payBankUrl, queryBankUrl, getPublicKeyUrl:
+		if c.tlsClient != nil {
+			return c.tlsClient, nil
+		}
+		defaultTls.mu.Lock()
+		tlsClient := defaultTls.client
+		defaultTls.mu.Unlock()
+		if tlsClient == nil {
+			globalLogger.printf("%s requires api certificate", path)
+			return nil, certNotSetErr
+		}
+		return tlsClient, nil
 	default:
-		return client
+		return c.httpClient, nil
 	}
 }
 
+// SetTlsClient 从文件加载PKCS#12格式的api证书，作为所有没有设置证书的Client的默认证书。
+//
+// Deprecated: 使用NewClient和WithCertFile为每个Client单独设置证书
 func SetTlsClient(path, password string) error {
-	p12, err := ioutil.ReadFile(path)
-	if err != nil {
-		globalLogger.printf("ReadFile err: %v", err)
+	c := &Client{}
+	if err := WithCertFile(path, password)(c); err != nil {
 		return err
 	}
-	blocks, err := pkcs12.ToPEM(p12, password)
-	if err != nil {
-		globalLogger.printf("ToPEM err: %v", err)
-		return err
+	tlsClient := newHttpClient()
+	tlsClient.Transport = c.tlsTransport()
+
+	defaultTls.mu.Lock()
+	defaultTls.client = tlsClient
+	defaultTls.mu.Unlock()
+	return nil
+}
+
+// WithCertFile 从文件加载PKCS#12格式的api证书(apiclient_cert.p12)，password默认为商户号
+func WithCertFile(path, password string) Option {
+	return func(c *Client) error {
+		p12, err := ioutil.ReadFile(path)
+		if err != nil {
+			globalLogger.printf("ReadFile err: %v", err)
+			return err
+		}
+		return WithCertBytes(p12, password)(c)
 	}
-	var pemData []byte
-	for _, b := range blocks {
-		pemData = append(pemData, pem.EncodeToMemory(b)...)
+}
+
+// WithCertBytes 加载PKCS#12格式的api证书内容
+func WithCertBytes(p12 []byte, password string) Option {
+	return func(c *Client) error {
+		blocks, err := pkcs12.ToPEM(p12, password)
+		if err != nil {
+			globalLogger.printf("ToPEM err: %v", err)
+			return err
+		}
+		var pemData []byte
+		for _, b := range blocks {
+			pemData = append(pemData, pem.EncodeToMemory(b)...)
+		}
+		return WithCertPEM(pemData, pemData)(c)
 	}
+}
 
-	cert, err := tls.X509KeyPair(pemData, pemData)
-	if err != nil {
-		globalLogger.printf("X509KeyPair err: %v", err)
-		return err
+// WithCertPEM 加载PEM格式的api证书(apiclient_cert.pem)和私钥(apiclient_key.pem)
+func WithCertPEM(certPEM, keyPEM []byte) Option {
+	return func(c *Client) error {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			globalLogger.printf("X509KeyPair err: %v", err)
+			return err
+		}
+		c.certificates = []tls.Certificate{cert}
+		return nil
 	}
-	tlsClient.Transport = &http.Transport{
-		TLSClientConfig: &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		},
+}
+
+// WithTransport 使用自定义的http.RoundTripper，
+// 如果rt是*http.Transport，设置的api证书会加到它的副本上，其他类型的RoundTripper需要自行处理证书
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) error {
+		if rt == nil {
+			return errors.New("nil transport")
+		}
+		c.transport = rt
+		return nil
 	}
-	return nil
+}
+
+// 所有Option执行完后根据配置生成http客户端
+func (c *Client) initHttpClients() {
+	c.httpClient = newHttpClient()
+	if c.transport != nil {
+		c.httpClient.Transport = c.transport
+	}
+
+	// 没有证书时不生成tlsClient，需要证书的接口在本地返回certNotSetErr
+	if c.certificates != nil {
+		c.tlsClient = newHttpClient()
+		c.tlsClient.Transport = c.tlsTransport()
+	}
+}
+
+func (c *Client) tlsTransport() http.RoundTripper {
+	var transport *http.Transport
+	switch t := c.transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return c.transport
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = new(tls.Config)
+	}
+	transport.TLSClientConfig.Certificates = c.certificates
+	return transport
 }
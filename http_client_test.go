@@ -0,0 +1,138 @@
+package wxpay
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testCertPEM(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "1900000109"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// 需要证书的接口在测试中使用自签名证书
+func withTestCert(t *testing.T) Option {
+	certPEM, keyPEM := testCertPEM(t)
+	return WithCertPEM(certPEM, keyPEM)
+}
+
+func TestWithCertPEM(t *testing.T) {
+	certPEM, keyPEM := testCertPEM(t)
+	c1, err := NewClient(testApiKey, "1900000109", WithCertPEM(certPEM, keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2 := New(testApiKey, "1900000110")
+
+	client, err := c1.selectedClient(refundUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || len(transport.TLSClientConfig.Certificates) != 1 {
+		t.Errorf("certificate not set: %#v", client.Transport)
+	}
+
+	if _, err := c2.selectedClient(refundUrl); err != certNotSetErr {
+		t.Errorf("expect %v, got %v", certNotSetErr, err)
+	}
+	if client, _ := c2.selectedClient(orderQueryUrl); client == c1.httpClient {
+		t.Error("clients should not share http.Client")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestWithTransport(t *testing.T) {
+	var called bool
+	rt := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		called = true
+		return nil, Error("stop")
+	})
+	c, err := NewClient(testApiKey, "1900000109", WithTransport(rt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.post(context.Background(), orderQueryUrl, &OrderQueryRequest{}); err == nil {
+		t.Error("expect error")
+	}
+	if !called {
+		t.Error("transport not used")
+	}
+
+	// 没有证书时需要证书的接口不发出请求
+	called = false
+	if _, err := c.post(context.Background(), refundUrl, &RefundRequest{}); err != certNotSetErr {
+		t.Errorf("expect %v, got %v", certNotSetErr, err)
+	}
+	if called {
+		t.Error("request sent without certificate")
+	}
+
+	// 其他类型的RoundTripper自行处理证书
+	c, err = NewClient(testApiKey, "1900000109", WithTransport(rt), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.post(context.Background(), refundUrl, &RefundRequest{}); err == nil || err == certNotSetErr {
+		t.Errorf("err: %v", err)
+	}
+	if !called {
+		t.Error("transport not used")
+	}
+}
+
+func TestSetTlsClient(t *testing.T) {
+	if err := SetTlsClient("testdata/not_exist.p12", ""); err == nil {
+		t.Error("expect error")
+	}
+
+	// 没有设置证书的Client使用默认证书
+	tlsClient := newHttpClient()
+	defaultTls.client = tlsClient
+	defer func() { defaultTls.client = nil }()
+
+	c := New(testApiKey, "1900000109")
+	if client, err := c.selectedClient(refundUrl); err != nil || client != tlsClient {
+		t.Errorf("client: %v, err: %v", client, err)
+	}
+	certPEM, keyPEM := testCertPEM(t)
+	c, err := NewClient(testApiKey, "1900000109", WithCertPEM(certPEM, keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client, _ := c.selectedClient(refundUrl); client == tlsClient {
+		t.Error("client certificate should take precedence")
+	}
+}
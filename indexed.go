@@ -0,0 +1,155 @@
+package wxpay
+
+import (
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 微信用coupon_id_0、coupon_refund_id_0_1这样带下标的字段表示列表，
+// 结构体字段的xml tag中$n表示第一级下标，$m表示第二级下标，例如：
+//
+//	type Coupon struct {
+//		CouponId string `xml:"coupon_id_$n"`
+//	}
+//
+// 元素为这种结构体的切片字段，会按下标从0开始依次填充，直到某个下标的字段都不存在。
+// 字段的值无法转换成对应类型时返回错误
+func UnmarshalIndexed(body []byte, v interface{}) error {
+	if err := xml.Unmarshal(body, v); err != nil {
+		return err
+	}
+	return unmarshalIndexed(body, v)
+}
+
+// 只填充带下标的字段，普通字段已经由xml.Unmarshal填充
+func unmarshalIndexed(body []byte, v interface{}) error {
+	m := make(Map)
+	if err := xml.Unmarshal(body, &m); err != nil {
+		return err
+	}
+	return decodeIndexed(m, reflect.ValueOf(v).Elem(), nil)
+}
+
+// indexes为当前结构体所在的下标，顶层结构体为nil
+func decodeIndexed(m Map, val reflect.Value, indexes []string) error {
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		typeField := val.Type().Field(i)
+
+		if elemType, ok := indexedElem(typeField.Type); ok {
+			if err := decodeIndexedSlice(m, field, elemType, indexes); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := strings.Split(typeField.Tag.Get("xml"), ",")[0]
+		if !strings.Contains(name, "$") {
+			continue
+		}
+		key := expandIndex(name, indexes)
+		value, ok := m[key]
+		if !ok {
+			continue
+		}
+		if err := setIndexedField(field, value); err != nil {
+			return fmt.Errorf("wxpay: unmarshal %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func decodeIndexedSlice(m Map, field reflect.Value, elemType reflect.Type, indexes []string) error {
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+
+	slice := reflect.MakeSlice(field.Type(), 0, 0)
+	for n := 0; ; n++ {
+		elemIndexes := append(append([]string(nil), indexes...), strconv.Itoa(n))
+		if !hasIndexedKey(m, structType, elemIndexes) {
+			break
+		}
+		elem := reflect.New(structType)
+		if err := decodeIndexed(m, elem.Elem(), elemIndexes); err != nil {
+			return err
+		}
+		if elemType.Kind() == reflect.Ptr {
+			slice = reflect.Append(slice, elem)
+		} else {
+			slice = reflect.Append(slice, elem.Elem())
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+// 切片元素是否为带下标字段的结构体
+func indexedElem(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Slice {
+		return nil, false
+	}
+	elemType := t.Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, false
+	}
+	for i := 0; i < structType.NumField(); i++ {
+		if strings.Contains(structType.Field(i).Tag.Get("xml"), "$") {
+			return elemType, true
+		}
+	}
+	return nil, false
+}
+
+// 该下标下是否存在任一字段
+func hasIndexedKey(m Map, structType reflect.Type, indexes []string) bool {
+	for i := 0; i < structType.NumField(); i++ {
+		name := strings.Split(structType.Field(i).Tag.Get("xml"), ",")[0]
+		if !strings.Contains(name, "$") {
+			continue
+		}
+		if _, ok := m[expandIndex(name, indexes)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func expandIndex(name string, indexes []string) string {
+	for i, placeholder := range []string{"$n", "$m"} {
+		if i < len(indexes) {
+			name = strings.Replace(name, placeholder, indexes[i], -1)
+		}
+	}
+	return name
+}
+
+func setIndexedField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intVal, err := strconv.ParseInt(strings.TrimSpace(value), 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(intVal)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintVal, err := strconv.ParseUint(strings.TrimSpace(value), 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(uintVal)
+	default:
+		return fmt.Errorf("unsupported kind %s", field.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package wxpay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalIndexed_Coupons(t *testing.T) {
+	body := []byte(`<xml>
+   <return_code><![CDATA[SUCCESS]]></return_code>
+   <result_code><![CDATA[SUCCESS]]></result_code>
+   <coupon_fee>300</coupon_fee>
+   <coupon_count>2</coupon_count>
+   <coupon_type_0><![CDATA[CASH]]></coupon_type_0>
+   <coupon_id_0><![CDATA[10000]]></coupon_id_0>
+   <coupon_fee_0>100</coupon_fee_0>
+   <coupon_type_1><![CDATA[NO_CASH]]></coupon_type_1>
+   <coupon_id_1><![CDATA[10001]]></coupon_id_1>
+   <coupon_fee_1>200</coupon_fee_1>
+</xml>`)
+	var resp OrderQueryResponse
+	if err := unmarshalIndexed(body, &resp); err != nil {
+		t.Fatal(err)
+	}
+	expect := []*Coupon{
+		{CouponType: CouponTypeCash, CouponId: "10000", CouponFee: 100},
+		{CouponType: CouponTypeNoCash, CouponId: "10001", CouponFee: 200},
+	}
+	if !reflect.DeepEqual(resp.Coupons, expect) {
+		t.Errorf("coupons: %+v", resp.Coupons)
+	}
+}
+
+func TestUnmarshalIndexed_Nested(t *testing.T) {
+	var detail RefundDetail
+	m := Map{
+		"coupon_type_1_0":       "CASH",
+		"coupon_refund_id_1_0":  "10000",
+		"coupon_refund_fee_1_0": "100",
+		"coupon_refund_id_1_1":  "10001",
+		"coupon_refund_fee_1_1": "200",
+		"coupon_refund_id_0_0":  "20000",
+	}
+	if err := decodeIndexed(m, reflect.ValueOf(&detail).Elem(), []string{"1"}); err != nil {
+		t.Fatal(err)
+	}
+	expect := []*RefundDetailCoupon{
+		{CouponType: CouponTypeCash, CouponRefundId: "10000", CouponRefundFee: 100},
+		{CouponRefundId: "10001", CouponRefundFee: 200},
+	}
+	if !reflect.DeepEqual(detail.Coupons, expect) {
+		t.Errorf("coupons: %+v", detail.Coupons)
+	}
+}
+
+func TestUnmarshalIndexed_Error(t *testing.T) {
+	body := []byte(`<xml><coupon_refund_id_0>1</coupon_refund_id_0><coupon_refund_fee_0>1.00</coupon_refund_fee_0></xml>`)
+	var resp RefundResponse
+	if err := unmarshalIndexed(body, &resp); err == nil {
+		t.Error("expect error")
+	}
+}
@@ -1,25 +1,47 @@
 package wxpay
 
 const (
-	errCodeNoAuth             = "NOAUTH"                // 商户无此接口权限
-	errCodeNotEnough          = "NOTENOUGH"             // 余额不足
-	errCodeTradeOverDue       = "TRADE_OVERDUE"         //  订单已经超过退款期限
-	errCodeOrderPaid          = "ORDERPAID"             // 商户订单已支付
-	errCodeOrderClosed        = "ORDERCLOSED"           // 订单已关闭
-	errCodeSystemError        = "SYSTEMERROR"           // 系统错误
-	errCodeAppidNotExist      = "APPID_NOT_EXIST"       // APPID不存在
-	errCodeMchidNotExist      = "MCHID_NOT_EXIST"       // MCHID不存在
-	errCodeAppidMchidNotMatch = "APPID_MCHID_NOT_MATCH" // appid和mch_id不匹配
-	errCodeLackParams         = "LACK_PARAMS"           // 缺少参数
-	errCodeOutTradeNoUsed     = "OUT_TRADE_NO_USED"     // 商户订单号重复
-	errCodeSignError          = "SIGNERROR"             // 签名错误
-	errCodeXmlFormatError     = "XML_FORMAT_ERROR"      // XML格式错误
-	errCodeRequirePostMethod  = "REQUIRE_POST_METHOD"   // 请使用post方法
-	errCodePostDataEmpty      = "POST_DATA_EMPTY"       // post数据为空
-	errCodeNotUtf8            = "NOT_UTF8"              // 编码格式错误
-	errCodeOrderNotExist      = "ORDERNOTEXIST"         // 此交易订单号不存在
-	errCodeBizerrNeedRetry    = "BIZERR_NEED_RETRY"     // 退款业务流程错误，需要商户触发重试来解决
-	errCodeRefundNotExist     = "REFUNDNOTEXIST"        // 退款订单查询失败 订单号错误或订单状态不正确
+	errCodeNoAuth               = "NOAUTH"                // 商户无此接口权限
+	errCodeNotEnough            = "NOTENOUGH"             // 余额不足
+	errCodeTradeOverDue         = "TRADE_OVERDUE"         //  订单已经超过退款期限
+	errCodeOrderPaid            = "ORDERPAID"             // 商户订单已支付
+	errCodeOrderClosed          = "ORDERCLOSED"           // 订单已关闭
+	errCodeSystemError          = "SYSTEMERROR"           // 系统错误
+	errCodeAppidNotExist        = "APPID_NOT_EXIST"       // APPID不存在
+	errCodeMchidNotExist        = "MCHID_NOT_EXIST"       // MCHID不存在
+	errCodeAppidMchidNotMatch   = "APPID_MCHID_NOT_MATCH" // appid和mch_id不匹配
+	errCodeLackParams           = "LACK_PARAMS"           // 缺少参数
+	errCodeOutTradeNoUsed       = "OUT_TRADE_NO_USED"     // 商户订单号重复
+	errCodeSignError            = "SIGNERROR"             // 签名错误
+	errCodeXmlFormatError       = "XML_FORMAT_ERROR"      // XML格式错误
+	errCodeRequirePostMethod    = "REQUIRE_POST_METHOD"   // 请使用post方法
+	errCodePostDataEmpty        = "POST_DATA_EMPTY"       // post数据为空
+	errCodeNotUtf8              = "NOT_UTF8"              // 编码格式错误
+	errCodeOrderNotExist        = "ORDERNOTEXIST"         // 此交易订单号不存在
+	errCodeBizerrNeedRetry      = "BIZERR_NEED_RETRY"     // 退款业务流程错误，需要商户触发重试来解决
+	errCodeRefundNotExist       = "REFUNDNOTEXIST"        // 退款订单查询失败 订单号错误或订单状态不正确
+	errCodeInvalidRequest       = "INVALID_REQUEST"       // 参数错误
+	errCodeParamError           = "PARAM_ERROR"           // 参数错误
+	errCodeFrequencyLimited     = "FREQUENCY_LIMITED"     // 频率限制
+	errCodeUserAccountAbnormal  = "USER_ACCOUNT_ABNORMAL" // 退款请求失败，用户帐号注销
+	errCodeInvalidTransactionId = "INVALID_TRANSACTIONID" // 无效transaction_id
+	errCodeRefundError          = "ERROR"                 // 业务错误，申请退款业务发生错误
+	errCodeUserPaying           = "USERPAYING"            // 用户支付中，需要输入密码
+	errCodeBankError            = "BANKERROR"             // 银行系统异常
+	errCodeAuthCodeExpire       = "AUTHCODEEXPIRE"        // 二维码已过期，请用户在微信上刷新后再试
+	errCodeAuthCodeError        = "AUTH_CODE_ERROR"       // 授权码参数错误
+	errCodeAuthCodeInvalid      = "AUTH_CODE_INVALID"     // 授权码检验错误
+	errCodeSendFailed           = "SEND_FAILED"           // 企业付款错误，需要查询付款结果
+	errCodeNotFound             = "NOT_FOUND"             // 企业付款的付款单不存在
+	errCodePayError             = "PAYERROR"              // 支付失败，用户需要重新支付
+	errCodeBuyerMismatch        = "BUYER_MISMATCH"        // 支付帐号错误，暂不支持同一笔订单更换支付方
+	errCodeOrderReversed        = "ORDERREVERSED"         // 订单已撤销
+	errCodeAmountLimit          = "AMOUNT_LIMIT"          // 企业付款金额超限
+	errCodeOpenidError          = "OPENID_ERROR"          // openid和appid不匹配
+	errCodeNameMismatch         = "NAME_MISMATCH"         // 收款人姓名校验不一致
+	errCodeV2AccountSimpleBan   = "V2_ACCOUNT_SIMPLE_BAN" // 无法给未实名用户付款
+	errCodeMoneyLimit           = "MONEY_LIMIT"           // 已经达到今日付款总额上限/已达到付款给此用户额度上限
+	errCodeSendNumLimit         = "SENDNUM_LIMIT"         // 该用户今日付款次数超过限制
 )
 
 const (
@@ -35,6 +57,10 @@ type Meta struct {
 	ErrCodeDes string `xml:"err_code_des"` // 当result_code为FAIL时返回错误描述，详细参见下文错误列表
 }
 
+func (meta *Meta) meta() *Meta {
+	return meta
+}
+
 // 通信标识
 func (meta Meta) returnCodeSuccess() bool {
 	return meta.ReturnCode == success
@@ -48,6 +74,20 @@ func (meta Meta) ResultCodeSuccess() bool {
 	return meta.ResultCode == success
 }
 
+// Err 业务成功时返回nil，否则返回*APIError
+func (meta Meta) Err() error {
+	if meta.ResultCodeSuccess() {
+		return nil
+	}
+	return &APIError{
+		ReturnCode: meta.ReturnCode,
+		ReturnMsg:  meta.ReturnMsg,
+		ResultCode: meta.ResultCode,
+		ErrCode:    meta.ErrCode,
+		ErrCodeDes: meta.ErrCodeDes,
+	}
+}
+
 func (meta Meta) IsSystemErr() bool {
 	return meta.ErrCode == errCodeSystemError
 }
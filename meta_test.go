@@ -1,6 +1,9 @@
 package wxpay
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestMeta_IsNotEnough(t *testing.T) {
 	meta := &Meta{
@@ -31,3 +34,49 @@ func TestMeta_IsRefundNotExist(t *testing.T) {
 		t.Error("REFUNDNOTEXIST")
 	}
 }
+
+func TestMeta_Err(t *testing.T) {
+	meta := Meta{
+		ReturnCode: "SUCCESS",
+		ResultCode: "FAIL",
+		ErrCode:    "ORDERPAID",
+		ErrCodeDes: "商户订单已支付",
+	}
+	err := meta.Err()
+	if !errors.Is(err, ErrOrderPaid) || errors.Is(err, ErrOrderClosed) {
+		t.Error(err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrCodeDes != "商户订单已支付" {
+		t.Error(err)
+	}
+
+	meta.ErrCode = "V2_ACCOUNT_SIMPLE_BAN"
+	if err := meta.Err(); !errors.Is(err, ErrV2AccountSimpleBan) {
+		t.Error(err)
+	}
+
+	meta = Meta{ReturnCode: "SUCCESS", ResultCode: "SUCCESS"}
+	if err := meta.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_WithBusinessError(t *testing.T) {
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		return Map{
+			"return_code": "SUCCESS",
+			"result_code": "FAIL",
+			"err_code":    "ORDERNOTEXIST",
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithBusinessError())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.OrderQuery(&OrderQueryRequest{OutTradeNo: "1415757673"}); !errors.Is(err, ErrOrderNotExist) {
+		t.Errorf("expect %v, got %v", ErrOrderNotExist, err)
+	}
+}
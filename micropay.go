@@ -0,0 +1,248 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// https://pay.weixin.qq.com/wiki/doc/api/micropay.php?chapter=9_10&index=1
+
+const (
+	microPayUrl = "/pay/micropay"
+)
+
+type MicroPayRequest struct {
+	XMLName        xml.Name `xml:"xml"`
+	AppId          string   `xml:"appid,omitempty"`
+	MchId          string   `xml:"mch_id,omitempty"`
+	SubAppId       string   `xml:"sub_appid,omitempty"`
+	SubMchId       string   `xml:"sub_mch_id,omitempty"`
+	DeviceInfo     string   `xml:"device_info,omitempty"`
+	NonceStr       string   `xml:"nonce_str,omitempty"`
+	Sign           string   `xml:"sign,omitempty"`
+	SignType       string   `xml:"sign_type,omitempty"`
+	Body           string   `xml:"body,omitempty"`
+	Detail         string   `xml:"detail,omitempty"`
+	Attach         string   `xml:"attach,omitempty"`
+	OutTradeNo     string   `xml:"out_trade_no,omitempty"`
+	TotalFee       int64    `xml:"total_fee,omitempty"`
+	FeeType        string   `xml:"fee_type,omitempty"`
+	SpBillCreateIp string   `xml:"spbill_create_ip,omitempty"`
+	GoodsTag       string   `xml:"goods_tag,omitempty"`
+	LimitPay       string   `xml:"limit_pay,omitempty"`
+	TimeStart      string   `xml:"time_start,omitempty"`
+	TimeExpire     string   `xml:"time_expire,omitempty"`
+	AuthCode       string   `xml:"auth_code,omitempty"` // 用户付款码
+	SceneInfo      string   `xml:"scene_info,omitempty"`
+}
+
+type MicroPayResponse struct {
+	Meta
+	AppId              string `xml:"appid"`
+	MchId              string `xml:"mch_id"`
+	SubAppId           string `xml:"sub_appid"`
+	SubMchId           string `xml:"sub_mch_id"`
+	DeviceInfo         string `xml:"device_info"`
+	NonceStr           string `xml:"nonce_str"`
+	Sign               string `xml:"sign"`
+	OpenId             string `xml:"openid"`
+	IsSubscribe        string `xml:"is_subscribe"`
+	TradeType          string `xml:"trade_type"`
+	BankType           string `xml:"bank_type"`
+	FeeType            string `xml:"fee_type"`
+	TotalFee           int64  `xml:"total_fee"`
+	SettlementTotalFee int64  `xml:"settlement_total_fee"`
+	CouponFee          int64  `xml:"coupon_fee"`
+	CashFeeType        string `xml:"cash_fee_type"`
+	CashFee            int64  `xml:"cash_fee"`
+	TransactionId      string `xml:"transaction_id"`
+	OutTradeNo         string `xml:"out_trade_no"`
+	Attach             string `xml:"attach"`
+	TimeEnd            string `xml:"time_end"`
+}
+
+// 必填参数 body，out_trade_no，total_fee，spbill_create_ip，auth_code
+// 返回USERPAYING、SYSTEMERROR、BANKERROR时支付结果未知，需要查询订单，见MicroPayAndWait
+func (c *Client) MicroPay(request *MicroPayRequest) (*MicroPayResponse, error) {
+	return c.MicroPayContext(context.Background(), request)
+}
+
+func (c *Client) MicroPayContext(ctx context.Context, request *MicroPayRequest) (*MicroPayResponse, error) {
+	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
+	request.NonceStr = nonceStr()
+
+	if len(request.Body) == 0 {
+		return nil, errors.New("body is zero")
+	}
+
+	if len(request.OutTradeNo) == 0 {
+		return nil, errors.New("out_trade_no is zero")
+	}
+
+	if request.TotalFee <= 0 {
+		return nil, errors.New("wrong total_fee")
+	}
+
+	if len(request.SpBillCreateIp) == 0 {
+		return nil, errors.New("spbill_create_ip is zero")
+	}
+
+	if len(request.AuthCode) == 0 {
+		return nil, errors.New("auth_code is zero")
+	}
+
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
+	var response MicroPayResponse
+	_, err = c.request(ctx, microPayUrl, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+type MicroPayWaitOptions struct {
+	Interval   time.Duration // 查询订单的间隔，默认5秒
+	Timeout    time.Duration // 等待用户支付的最长时间，默认30秒
+	MaxReverse int           // 撤销订单的最多次数，默认10次
+}
+
+// MicroPayResult 付款码支付的最终结果
+type MicroPayResult struct {
+	Paid     bool
+	MicroPay *MicroPayResponse   // 提交付款码的返回，网络错误时为nil
+	Order    *OrderQueryResponse // 最后一次查询到的订单，没有查询时为nil
+	Reverse  *ReverseResponse    // 撤销订单的返回，没有撤销时为nil
+}
+
+// MicroPayAndWait 提交付款码后，在支付结果未知时轮询订单直到支付成功或超时，
+// 超时、支付失败或用户取消支付时撤销订单，撤销返回recall=Y时继续撤销。
+// 支付成功返回的error为nil，订单已撤销或关闭时返回的error满足IsMicroPayNotPaid
+func (c *Client) MicroPayAndWait(ctx context.Context, request *MicroPayRequest, opts *MicroPayWaitOptions) (*MicroPayResult, error) {
+	var o MicroPayWaitOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Second
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 30 * time.Second
+	}
+	if o.MaxReverse <= 0 {
+		o.MaxReverse = 10
+	}
+
+	var (
+		result   MicroPayResult
+		deadline = time.Now().Add(o.Timeout)
+		err      error
+	)
+	result.MicroPay, err = c.MicroPayContext(ctx, request)
+	switch {
+	case err != nil:
+		// 参数错误、签名失败等请求没有发出的错误，以及明确的业务错误直接返回，
+		// 否则可能撤销商户重复使用的out_trade_no对应的已支付订单
+		if !microPayUnknown(err) {
+			return &result, err
+		}
+		globalLogger.printf("micropay err: %v", err)
+	case result.MicroPay.ResultCodeSuccess():
+		result.Paid = true
+		return &result, nil
+	case result.MicroPay.ReturnCode == success:
+		if !microPayPending(result.MicroPay.ErrCode) {
+			return &result, result.MicroPay.Err()
+		}
+	}
+
+pollLoop:
+	for time.Now().Before(deadline) {
+		if err := sleep(ctx, o.Interval); err != nil {
+			return &result, err
+		}
+		order, err := c.OrderQueryContext(ctx, &OrderQueryRequest{
+			AppId:      request.AppId,
+			SubAppId:   request.SubAppId,
+			SubMchId:   request.SubMchId,
+			OutTradeNo: request.OutTradeNo,
+		})
+		if err != nil {
+			globalLogger.printf("orderquery err: %v", err)
+			continue
+		}
+		result.Order = order
+		if !order.ResultCodeSuccess() {
+			continue
+		}
+		switch order.TradeState {
+		case TradeStateSuccess:
+			result.Paid = true
+			return &result, nil
+		case TradeStateRevoked, TradeStateClosed:
+			return &result, microPayNotPaidErr
+		case TradeStateNotPay, TradeStatePayError:
+			// 用户取消输入密码时为NOTPAY，不会再支付成功
+			break pollLoop
+		}
+	}
+
+	reverseRequest := &ReverseRequest{
+		AppId:      request.AppId,
+		SubAppId:   request.SubAppId,
+		SubMchId:   request.SubMchId,
+		OutTradeNo: request.OutTradeNo,
+	}
+	for i := 0; i < o.MaxReverse; i++ {
+		if i > 0 {
+			if err := sleep(ctx, o.Interval); err != nil {
+				return &result, err
+			}
+		}
+		result.Reverse, err = c.ReverseContext(ctx, reverseRequest)
+		if err != nil {
+			globalLogger.printf("reverse err: %v", err)
+			continue
+		}
+		if result.Reverse.ResultCodeSuccess() {
+			return &result, microPayNotPaidErr
+		}
+		if result.Reverse.Recall != "Y" {
+			return &result, result.Reverse.Err()
+		}
+	}
+	if err != nil {
+		return &result, err
+	}
+	return &result, result.Reverse.Err()
+}
+
+// 返回提交付款码的error是否表示支付结果未知，只有网络错误和WithBusinessError时的部分业务错误需要查询订单
+func microPayUnknown(err error) bool {
+	switch err := err.(type) {
+	case *APIError:
+		return microPayPending(err.ErrCode)
+	case *url.Error, net.Error, *xml.SyntaxError:
+		return true
+	default:
+		return err == io.ErrUnexpectedEOF
+	}
+}
+
+// 支付结果未知，需要查询订单。其他错误是明确的失败，如付款码过期、余额不足，不需要撤销
+func microPayPending(errCode string) bool {
+	switch errCode {
+	case errCodeUserPaying, errCodeSystemError, errCodeBankError:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,175 @@
+package wxpay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newMicroPayRequest() *MicroPayRequest {
+	return &MicroPayRequest{
+		Body:           "image形象店-深圳腾大- QQ公仔",
+		OutTradeNo:     "1217752501201407033233368018",
+		TotalFee:       888,
+		SpBillCreateIp: "8.8.8.8",
+		AuthCode:       "120061098828009406",
+	}
+}
+
+func TestClient_MicroPayAndWait_Paid(t *testing.T) {
+	var queries int
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		switch path {
+		case microPayUrl:
+			return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "USERPAYING"}
+		case orderQueryUrl:
+			queries++
+			state := TradeStateUserPaying
+			if queries == 2 {
+				state = TradeStateSuccess
+			}
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "trade_state": state}
+		default:
+			t.Errorf("unexpected path: %s", path)
+			return Map{"return_code": "FAIL"}
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.MicroPayAndWait(context.Background(), newMicroPayRequest(), &MicroPayWaitOptions{
+		Interval: time.Millisecond,
+		Timeout:  time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Paid || queries != 2 || result.Reverse != nil {
+		t.Errorf("result: %+v, queries: %d", result, queries)
+	}
+}
+
+func TestClient_MicroPayAndWait_Reverse(t *testing.T) {
+	var reverses int
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		switch path {
+		case microPayUrl:
+			return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "USERPAYING"}
+		case orderQueryUrl:
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "trade_state": TradeStateUserPaying}
+		case reverseUrl:
+			reverses++
+			if reverses == 1 {
+				return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "USERPAYING", "recall": "Y"}
+			}
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "recall": "N"}
+		default:
+			t.Errorf("unexpected path: %s", path)
+			return Map{"return_code": "FAIL"}
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.MicroPayAndWait(context.Background(), newMicroPayRequest(), &MicroPayWaitOptions{
+		Interval: time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	})
+	if !IsMicroPayNotPaid(err) {
+		t.Errorf("expect not paid, got %v", err)
+	}
+	if result.Paid || reverses != 2 {
+		t.Errorf("result: %+v, reverses: %d", result, reverses)
+	}
+}
+
+func TestClient_MicroPayAndWait_BusinessError(t *testing.T) {
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		if path != microPayUrl {
+			t.Errorf("unexpected path: %s", path)
+		}
+		return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "AUTHCODEEXPIRE"}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithBusinessError())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 明确的失败直接返回，不查询也不撤销订单
+	_, err = c.MicroPayAndWait(context.Background(), newMicroPayRequest(), &MicroPayWaitOptions{
+		Interval: time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	})
+	if !errors.Is(err, ErrAuthCodeExpire) {
+		t.Errorf("err: %v", err)
+	}
+}
+
+func TestClient_MicroPayAndWait_NotPay(t *testing.T) {
+	var queries, reverses int
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		switch path {
+		case microPayUrl:
+			return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "USERPAYING"}
+		case orderQueryUrl:
+			queries++
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "trade_state": TradeStateNotPay}
+		case reverseUrl:
+			reverses++
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "recall": "N"}
+		default:
+			t.Errorf("unexpected path: %s", path)
+			return Map{"return_code": "FAIL"}
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 用户取消支付后不再轮询，直接撤销
+	_, err = c.MicroPayAndWait(context.Background(), newMicroPayRequest(), &MicroPayWaitOptions{
+		Interval: time.Millisecond,
+		Timeout:  time.Second,
+	})
+	if !IsMicroPayNotPaid(err) || queries != 1 || reverses != 1 {
+		t.Errorf("err: %v, queries: %d, reverses: %d", err, queries, reverses)
+	}
+}
+
+func TestClient_MicroPayAndWait_InvalidRequest(t *testing.T) {
+	calls := make(map[string]int)
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		calls[path]++
+		return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "trade_state": TradeStateSuccess}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 请求没有发出时不能查询和撤销订单，out_trade_no可能属于已支付的订单
+	request := newMicroPayRequest()
+	request.AuthCode = ""
+	_, err = c.MicroPayAndWait(context.Background(), request, &MicroPayWaitOptions{
+		Interval: time.Millisecond,
+		Timeout:  20 * time.Millisecond,
+	})
+	if err == nil || err.Error() != "auth_code is zero" {
+		t.Errorf("err: %v", err)
+	}
+	if len(calls) != 0 {
+		t.Errorf("calls: %v", calls)
+	}
+}
@@ -1,13 +1,24 @@
 package wxpay
 
 import (
+	"context"
 	"encoding/xml"
 )
 
 // https://pay.weixin.qq.com/wiki/doc/api/native.php?chapter=9_2
 
 const (
-	orderQueryUrl = "https://api.mch.weixin.qq.com/pay/orderquery"
+	orderQueryUrl = "/pay/orderquery"
+)
+
+const (
+	TradeStateSuccess    = "SUCCESS"    // 支付成功
+	TradeStateRefund     = "REFUND"     // 转入退款
+	TradeStateNotPay     = "NOTPAY"     // 未支付
+	TradeStateClosed     = "CLOSED"     // 已关闭
+	TradeStateRevoked    = "REVOKED"    // 已撤销（刷卡支付）
+	TradeStateUserPaying = "USERPAYING" // 用户支付中
+	TradeStatePayError   = "PAYERROR"   // 支付失败(其他原因，如银行返回失败)
 )
 
 // transaction_id 和 out_trade_no 2选1
@@ -15,6 +26,8 @@ type OrderQueryRequest struct {
 	XMLName       xml.Name `xml:"xml"`
 	AppId         string   `xml:"appid,omitempty"`
 	MchId         string   `xml:"mch_id,omitempty"`
+	SubAppId      string   `xml:"sub_appid,omitempty"`
+	SubMchId      string   `xml:"sub_mch_id,omitempty"`
 	TransactionId string   `xml:"transaction_id,omitempty"`
 	OutTradeNo    string   `xml:"out_trade_no,omitempty"`
 	NonceStr      string   `xml:"nonce_str,omitempty"`
@@ -24,38 +37,52 @@ type OrderQueryRequest struct {
 
 type OrderQueryResponse struct {
 	Meta
-	AppId              string `xml:"appid"`
-	MchId              string `xml:"mch_id"`
-	NonceStr           string `xml:"nonce_str"`
-	Sign               string `xml:"sign"`
-	DeviceInfo         string `xml:"device_info"`
-	OpenId             string `xml:"openid"`
-	IsSubscribe        string `xml:"is_subscribe"`
-	TradeType          string `xml:"trade_type"`
-	TradeState         string `xml:"trade_state"`
-	BankType           string `xml:"bank_type"`
-	TotalFee           int64  `xml:"total_fee"`
-	SettlementTotalFee int64  `xml:"settlement_total_fee"`
-	FeeType            string `xml:"fee_type"`
-	CashFee            int64  `xml:"cash_fee"`
-	CashFeeType        string `xml:"cash_fee_type"`
-	CouponFee          int64  `xml:"coupon_fee"`
-	CouponCount        int    `xml:"coupon_count"`
-	TransactionId      string `xml:"transaction_id"`
-	OutTradeNo         string `xml:"out_trade_no"`
-	Attach             string `xml:"attach"`
-	TimeEnd            string `xml:"time_end"`
-	TradeStateDesc     string `xml:"trade_state_desc"`
+	AppId              string    `xml:"appid"`
+	MchId              string    `xml:"mch_id"`
+	SubAppId           string    `xml:"sub_appid"`
+	SubMchId           string    `xml:"sub_mch_id"`
+	NonceStr           string    `xml:"nonce_str"`
+	Sign               string    `xml:"sign"`
+	DeviceInfo         string    `xml:"device_info"`
+	OpenId             string    `xml:"openid"`
+	IsSubscribe        string    `xml:"is_subscribe"`
+	TradeType          string    `xml:"trade_type"`
+	TradeState         string    `xml:"trade_state"`
+	BankType           string    `xml:"bank_type"`
+	TotalFee           int64     `xml:"total_fee"`
+	SettlementTotalFee int64     `xml:"settlement_total_fee"`
+	FeeType            string    `xml:"fee_type"`
+	CashFee            int64     `xml:"cash_fee"`
+	CashFeeType        string    `xml:"cash_fee_type"`
+	CouponFee          int64     `xml:"coupon_fee"`
+	CouponCount        int       `xml:"coupon_count"`
+	Coupons            []*Coupon `xml:"-"`
+	TransactionId      string    `xml:"transaction_id"`
+	OutTradeNo         string    `xml:"out_trade_no"`
+	Attach             string    `xml:"attach"`
+	TimeEnd            string    `xml:"time_end"`
+	TradeStateDesc     string    `xml:"trade_state_desc"`
 }
 
 func (c *Client) OrderQuery(request *OrderQueryRequest) (*OrderQueryResponse, error) {
+	return c.OrderQueryContext(context.Background(), request)
+}
+
+func (c *Client) OrderQueryContext(ctx context.Context, request *OrderQueryRequest) (*OrderQueryResponse, error) {
 	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
 	request.NonceStr = nonceStr()
-	request.Sign = signStruct(request, c.apiKey)
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response OrderQueryResponse
-	_, err := c.request(orderQueryUrl, request, &response)
+	body, err := c.request(ctx, orderQueryUrl, request, &response)
 	if err != nil {
 		return nil, err
 	}
+	if err := unmarshalIndexed(body, &response); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
@@ -1,6 +1,7 @@
 package wxpay
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
 	"io/ioutil"
@@ -10,27 +11,30 @@ import (
 type PaidNotifyRequest struct {
 	XMLName xml.Name `xml:"xml"`
 	Meta
-	AppId              string `xml:"appid"`
-	MchId              string `xml:"mch_id"`
-	DeviceInfo         string `xml:"device_info"`
-	NonceStr           string `xml:"nonce_str"`
-	Sign               string `xml:"sign"`
-	SignType           string `xml:"sign_type"`
-	OpenId             string `xml:"openid"`
-	IsSubscribe        string `xml:"is_subscribe"`
-	TradeType          string `xml:"trade_type"`
-	BankType           string `xml:"bank_type"`
-	TotalFee           int64  `xml:"total_fee"`
-	SettlementTotalFee int64  `xml:"settlement_total_fee"` // 应结订单金额
-	FeeType            string `xml:"fee_type"`
-	CashFee            int64  `xml:"cash_fee"`
-	CashFeeType        string `xml:"cash_fee_type"`
-	CouponFee          int64  `xml:"coupon_fee"`     // 总代金券金额
-	CouponCount        int    `xml:"coupon_count"`   // 代金券使用数量
-	TransactionId      string `xml:"transaction_id"` // 微信支付订单号
-	OutTradeNo         string `xml:"out_trade_no"`   // 商户订单号
-	Attach             string `xml:"attach"`         // 商家数据包
-	TimeEnd            string `xml:"time_end"`       // 支付完成时间
+	AppId              string    `xml:"appid"`
+	MchId              string    `xml:"mch_id"`
+	SubAppId           string    `xml:"sub_appid"`
+	SubMchId           string    `xml:"sub_mch_id"`
+	DeviceInfo         string    `xml:"device_info"`
+	NonceStr           string    `xml:"nonce_str"`
+	Sign               string    `xml:"sign"`
+	SignType           string    `xml:"sign_type"`
+	OpenId             string    `xml:"openid"`
+	IsSubscribe        string    `xml:"is_subscribe"`
+	TradeType          string    `xml:"trade_type"`
+	BankType           string    `xml:"bank_type"`
+	TotalFee           int64     `xml:"total_fee"`
+	SettlementTotalFee int64     `xml:"settlement_total_fee"` // 应结订单金额
+	FeeType            string    `xml:"fee_type"`
+	CashFee            int64     `xml:"cash_fee"`
+	CashFeeType        string    `xml:"cash_fee_type"`
+	CouponFee          int64     `xml:"coupon_fee"`     // 总代金券金额
+	CouponCount        int       `xml:"coupon_count"`   // 代金券使用数量
+	Coupons            []*Coupon `xml:"-"`              // 代金券明细
+	TransactionId      string    `xml:"transaction_id"` // 微信支付订单号
+	OutTradeNo         string    `xml:"out_trade_no"`   // 商户订单号
+	Attach             string    `xml:"attach"`         // 商家数据包
+	TimeEnd            string    `xml:"time_end"`       // 支付完成时间
 }
 
 type PaidNotifyResponse struct {
@@ -39,35 +43,101 @@ type PaidNotifyResponse struct {
 	ReturnMsg  string   `xml:"return_msg"`
 }
 
+// PaidVerify 校验支付结果通知，校验失败时返回return_code为FAIL的应答和error，
+// 支付失败(result_code为FAIL)的通知同样会返回，需要调用方用ResultCodeSuccess判断
 func (c *Client) PaidVerify(body []byte) (*PaidNotifyRequest, *PaidNotifyResponse, error) {
-	var (
-		notifyRequest  PaidNotifyRequest
-		notifyResponse PaidNotifyResponse
-	)
+	var notifyRequest PaidNotifyRequest
 	err := xml.Unmarshal(body, &notifyRequest)
+	if err == nil {
+		err = unmarshalIndexed(body, &notifyRequest)
+	}
 	if err != nil {
-		return nil, nil, err
+		return nil, failNotifyResponse("参数格式校验错误"), err
 	}
 
-	if !notifyRequest.ResultCodeSuccess() {
-		return nil, nil, errors.New("业务结果不成功")
+	if notifyRequest.ReturnCode != success {
+		return nil, failNotifyResponse("通信结果不成功"), errors.New("通信结果不成功: " + notifyRequest.ReturnMsg)
 	}
 
-	err = checkSign(body, c.apiKey)
+	// 沙箱环境的通知使用沙箱密钥签名
+	key, err := c.signKey(context.Background())
+	if err != nil {
+		return nil, failNotifyResponse("系统错误"), err
+	}
+	err = checkSign(body, key, SignTypeMD5)
 	if err != nil {
-		return nil, nil, err
+		return nil, failNotifyResponse("签名失败"), err
 	}
 
-	notifyResponse.ReturnCode = "SUCCESS"
-	notifyResponse.ReturnMsg = "OK"
-	return &notifyRequest, &notifyResponse, nil
+	err = c.checkPaidOrder(&notifyRequest)
+	if err != nil {
+		notifyAsync(string(body), err)
+		// 查询订单的错误可能包含数据库等内部信息，不返回给微信
+		return nil, failNotifyResponse("订单校验失败"), err
+	}
+
+	return &notifyRequest, &PaidNotifyResponse{ReturnCode: success, ReturnMsg: "OK"}, nil
 }
 
 func (c *Client) PaidNotifyVerify(request *http.Request) (*PaidNotifyRequest, *PaidNotifyResponse, error) {
 	body, err := ioutil.ReadAll(request.Body)
 	if err != nil {
-		return nil, nil, err
+		return nil, failNotifyResponse("读取通知失败"), err
 	}
 	globalLogger.printf("%s: %s", request.URL.String(), string(body))
 	return c.PaidVerify(body)
 }
+
+func failNotifyResponse(msg string) *PaidNotifyResponse {
+	return &PaidNotifyResponse{
+		ReturnCode: fail,
+		ReturnMsg:  msg,
+	}
+}
+
+// OrderRecord 商户系统中保存的订单，用于核对支付通知
+type OrderRecord struct {
+	AppId    string // 下单时使用的appid，为空时不校验
+	TotalFee int64  // 订单金额，单位分
+}
+
+type OrderLookup interface {
+	// LookupOrder 按商户订单号查找订单，订单不存在时返回nil, nil
+	LookupOrder(outTradeNo string) (*OrderRecord, error)
+}
+
+// WithOrderLookup 支付通知校验签名后，再与商户系统中的订单核对appid和金额
+func WithOrderLookup(lookup OrderLookup) Option {
+	return func(c *Client) error {
+		c.orderLookup = lookup
+		return nil
+	}
+}
+
+// 核对支付通知中的商户号、appid和金额
+func (c *Client) checkPaidOrder(request *PaidNotifyRequest) error {
+	if request.MchId != c.mchId {
+		return mchIdNotMatchErr
+	}
+	if c.subMchId != "" && request.SubMchId != c.subMchId {
+		return mchIdNotMatchErr
+	}
+
+	if c.orderLookup == nil {
+		return nil
+	}
+	order, err := c.orderLookup.LookupOrder(request.OutTradeNo)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return orderNotFoundErr
+	}
+	if order.AppId != "" && order.AppId != request.AppId {
+		return appIdNotMatchErr
+	}
+	if order.TotalFee != request.TotalFee {
+		return totalFeeNotMatchErr
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package wxpay
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// NotifyStore 记录已经处理过的支付通知，用于过滤微信的重复通知。
+// key为transaction_id，支付失败的通知没有transaction_id时为"out_trade_no:result_code"
+type NotifyStore interface {
+	// Processed 返回key对应的通知是否已经处理过
+	Processed(key string) (bool, error)
+	// MarkProcessed 业务处理成功后记录key
+	MarkProcessed(key string) error
+}
+
+// MemoryNotifyStore 保存在内存中的NotifyStore，仅适用于单进程部署
+type MemoryNotifyStore struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+func NewMemoryNotifyStore() *MemoryNotifyStore {
+	return &MemoryNotifyStore{
+		ids: make(map[string]struct{}),
+	}
+}
+
+func (s *MemoryNotifyStore) Processed(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.ids[key]
+	return ok, nil
+}
+
+func (s *MemoryNotifyStore) MarkProcessed(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids[key] = struct{}{}
+	return nil
+}
+
+// PaidNotifyHandler 处理支付结果通知的http.Handler，
+// 校验签名后调用Handle，并按结果给微信返回SUCCESS或FAIL
+type PaidNotifyHandler struct {
+	Client *Client
+	Handle func(request *PaidNotifyRequest) error // 支付失败的通知也会调用，返回error时微信会重新通知
+	Store  NotifyStore                            // 为nil时不过滤重复通知
+
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+func NewPaidNotifyHandler(client *Client, handle func(request *PaidNotifyRequest) error, store NotifyStore) (*PaidNotifyHandler, error) {
+	if client == nil {
+		return nil, errors.New("nil client")
+	}
+	if handle == nil {
+		return nil, errors.New("nil handle")
+	}
+	return &PaidNotifyHandler{
+		Client: client,
+		Handle: handle,
+		Store:  store,
+	}, nil
+}
+
+func (h *PaidNotifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Client == nil || h.Handle == nil {
+		globalLogger.printf("PaidNotifyHandler: nil Client or Handle")
+		writeNotifyResponse(w, failNotifyResponse("系统错误"))
+		return
+	}
+	notifyRequest, notifyResponse, err := h.Client.PaidNotifyVerify(r)
+	if err != nil {
+		globalLogger.printf("PaidNotifyVerify err: %v", err)
+		writeNotifyResponse(w, notifyResponse)
+		return
+	}
+
+	key := notifyKey(notifyRequest)
+	if h.Store != nil {
+		// 同一笔订单的通知正在处理中，让微信稍后重新通知
+		if !h.acquire(key) {
+			writeNotifyResponse(w, failNotifyResponse("processing"))
+			return
+		}
+		defer h.release(key)
+
+		processed, err := h.Store.Processed(key)
+		if err != nil {
+			// 错误可能包含数据库等内部信息，不返回给微信
+			globalLogger.printf("NotifyStore.Processed err: %v", err)
+			writeNotifyResponse(w, failNotifyResponse("系统错误"))
+			return
+		}
+		if processed {
+			writeNotifyResponse(w, notifyResponse)
+			return
+		}
+	}
+
+	if err := h.Handle(notifyRequest); err != nil {
+		globalLogger.printf("handle paid notify err: %v", err)
+		writeNotifyResponse(w, failNotifyResponse("处理失败"))
+		return
+	}
+
+	if h.Store != nil {
+		if err := h.Store.MarkProcessed(key); err != nil {
+			notifyAsync("NotifyStore.MarkProcessed err: ", err)
+		}
+	}
+	writeNotifyResponse(w, notifyResponse)
+}
+
+// 支付失败的通知可能没有transaction_id，使用商户订单号和业务结果去重
+func notifyKey(request *PaidNotifyRequest) string {
+	if request.TransactionId != "" {
+		return request.TransactionId
+	}
+	return request.OutTradeNo + ":" + request.ResultCode
+}
+
+func (h *PaidNotifyHandler) acquire(key string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.inFlight == nil {
+		h.inFlight = make(map[string]struct{})
+	}
+	if _, ok := h.inFlight[key]; ok {
+		return false
+	}
+	h.inFlight[key] = struct{}{}
+	return true
+}
+
+func (h *PaidNotifyHandler) release(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.inFlight, key)
+}
+
+func writeNotifyResponse(w http.ResponseWriter, response interface{}) {
+	body, err := xml.Marshal(response)
+	if err != nil {
+		globalLogger.printf("xml marshal err: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write(body)
+}
@@ -0,0 +1,110 @@
+package wxpay
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func signedNotifyBody(t *testing.T, m Map) []byte {
+	m["sign"] = sign(m, testApiKey, m["sign_type"])
+	var buf bytes.Buffer
+	if err := xml.NewEncoder(&buf).EncodeElement(m, xml.StartElement{Name: xml.Name{Local: "xml"}}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func servePaidNotify(h *PaidNotifyHandler, body []byte) *PaidNotifyResponse {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/notify", bytes.NewReader(body)))
+	var response PaidNotifyResponse
+	xml.Unmarshal(w.Body.Bytes(), &response)
+	return &response
+}
+
+func TestPaidNotifyHandler(t *testing.T) {
+	body := signedNotifyBody(t, Map{
+		"return_code":    "SUCCESS",
+		"result_code":    "SUCCESS",
+		"mch_id":         "1900000109",
+		"nonce_str":      "5d2b6c2a8db53831f7eda20af46e531c",
+		"out_trade_no":   "1409811653",
+		"total_fee":      "1",
+		"transaction_id": "1004400740201409030005092168",
+	})
+
+	var handled int
+	fail := true
+	h, err := NewPaidNotifyHandler(New(testApiKey, "1900000109"), func(request *PaidNotifyRequest) error {
+		handled++
+		if fail {
+			return errors.New("db down")
+		}
+		return nil
+	}, NewMemoryNotifyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp := servePaidNotify(h, body); resp.ReturnCode != "FAIL" || resp.ReturnMsg != "处理失败" {
+		t.Errorf("response: %+v", resp)
+	}
+
+	fail = false
+	for i := 0; i < 2; i++ {
+		if resp := servePaidNotify(h, body); resp.ReturnCode != "SUCCESS" {
+			t.Errorf("response: %+v", resp)
+		}
+	}
+	if handled != 2 {
+		t.Errorf("handled %d times", handled)
+	}
+
+	tampered := bytes.Replace(body, []byte("<total_fee>1</total_fee>"), []byte("<total_fee>100</total_fee>"), 1)
+	if resp := servePaidNotify(h, tampered); resp.ReturnCode != "FAIL" {
+		t.Errorf("response: %+v", resp)
+	}
+}
+
+func TestNewPaidNotifyHandler_NilHandle(t *testing.T) {
+	if _, err := NewPaidNotifyHandler(New(testApiKey, "1900000109"), nil, nil); err == nil {
+		t.Error("expect error for nil handle")
+	}
+	h := &PaidNotifyHandler{Client: New(testApiKey, "1900000109")}
+	if resp := servePaidNotify(h, nil); resp.ReturnCode != "FAIL" {
+		t.Errorf("response: %+v", resp)
+	}
+}
+
+func TestPaidNotifyHandler_PayFailed(t *testing.T) {
+	// 支付失败的通知没有transaction_id，按out_trade_no和result_code去重
+	body := signedNotifyBody(t, Map{
+		"return_code":  "SUCCESS",
+		"result_code":  "FAIL",
+		"err_code":     "PAYERROR",
+		"mch_id":       "1900000109",
+		"nonce_str":    "5d2b6c2a8db53831f7eda20af46e531c",
+		"out_trade_no": "1409811653",
+		"total_fee":    "1",
+	})
+
+	var handled int
+	h, err := NewPaidNotifyHandler(New(testApiKey, "1900000109"), func(request *PaidNotifyRequest) error {
+		handled++
+		return nil
+	}, NewMemoryNotifyStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if resp := servePaidNotify(h, body); resp.ReturnCode != "SUCCESS" {
+			t.Errorf("response: %+v", resp)
+		}
+	}
+	if handled != 1 {
+		t.Errorf("handled %d times", handled)
+	}
+}
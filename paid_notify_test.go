@@ -1,7 +1,10 @@
 package wxpay
 
 import (
+	"bytes"
 	"encoding/xml"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -44,4 +47,130 @@ func TestUnmarshalPaidNotifyRequest(t *testing.T) {
 	} else {
 		t.Log(request)
 	}
+	if err := unmarshalIndexed(paidVerifyBody, request); err != nil {
+		t.Error(err)
+	} else if len(request.Coupons) != 1 || request.Coupons[0].CouponFee != 10 {
+		t.Errorf("coupons: %+v", request.Coupons)
+	}
+}
+
+type orderLookupFunc func(outTradeNo string) (*OrderRecord, error)
+
+func (f orderLookupFunc) LookupOrder(outTradeNo string) (*OrderRecord, error) {
+	return f(outTradeNo)
+}
+
+func TestClient_PaidVerifyOrderLookup(t *testing.T) {
+	orders := map[string]*OrderRecord{
+		"1409811653": {AppId: "wx2421b1c4370ec43b", TotalFee: 100},
+	}
+	c, err := NewClient(testApiKey, "1900000109", WithOrderLookup(orderLookupFunc(func(outTradeNo string) (*OrderRecord, error) {
+		return orders[outTradeNo], nil
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notify := func(mchId, outTradeNo, totalFee string) []byte {
+		return signedNotifyBody(t, Map{
+			"return_code":    "SUCCESS",
+			"result_code":    "SUCCESS",
+			"appid":          "wx2421b1c4370ec43b",
+			"mch_id":         mchId,
+			"out_trade_no":   outTradeNo,
+			"total_fee":      totalFee,
+			"transaction_id": "1004400740201409030005092168",
+		})
+	}
+
+	if _, _, err := c.PaidVerify(notify("1900000109", "1409811653", "100")); err != nil {
+		t.Error(err)
+	}
+	if _, _, err := c.PaidVerify(notify("1900000109", "1409811653", "1")); err != totalFeeNotMatchErr {
+		t.Errorf("expect %v, got %v", totalFeeNotMatchErr, err)
+	}
+	if _, _, err := c.PaidVerify(notify("1900000109", "1409811654", "100")); err != orderNotFoundErr {
+		t.Errorf("expect %v, got %v", orderNotFoundErr, err)
+	}
+	if _, _, err := c.PaidVerify(notify("1900000110", "1409811653", "100")); !IsOrderNotMatch(err) {
+		t.Errorf("expect mch_id not match, got %v", err)
+	}
+}
+
+func TestClient_PaidVerifyFailResponse(t *testing.T) {
+	c := New(testApiKey, "1900000109")
+
+	request, response, err := c.PaidVerify(signedNotifyBody(t, Map{
+		"return_code":  "SUCCESS",
+		"result_code":  "FAIL",
+		"err_code":     "PAYERROR",
+		"mch_id":       "1900000109",
+		"out_trade_no": "1409811653",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.ResultCodeSuccess() || request.ErrCode != "PAYERROR" || response.ReturnCode != "SUCCESS" {
+		t.Errorf("request: %+v, response: %+v", request, response)
+	}
+
+	request, response, err = c.PaidVerify(paidVerifyBody)
+	if err == nil || request != nil || response == nil || response.ReturnCode != "FAIL" || response.ReturnMsg == "" {
+		t.Errorf("request: %+v, response: %+v, err: %v", request, response, err)
+	}
+
+	if _, response, err := c.PaidVerify([]byte("not xml")); err == nil || response.ReturnCode != "FAIL" {
+		t.Errorf("response: %+v, err: %v", response, err)
+	}
+}
+
+func TestClient_PaidVerifyMchAppIdNeedsSign(t *testing.T) {
+	c := New(testApiKey, "1900000109")
+
+	forged := func(sign string) []byte {
+		m := Map{
+			"return_code":    "SUCCESS",
+			"result_code":    "SUCCESS",
+			"mch_appid":      "wx2421b1c4370ec43b",
+			"mch_id":         "1900000109",
+			"out_trade_no":   "1409811653",
+			"total_fee":      "100",
+			"transaction_id": "1004400740201409030005092168",
+		}
+		if sign != "" {
+			m["sign"] = sign
+		}
+		var buf bytes.Buffer
+		if err := xml.NewEncoder(&buf).EncodeElement(m, xml.StartElement{Name: xml.Name{Local: "xml"}}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, body := range [][]byte{forged(""), forged("B552ED6B279343CB493C5DD0D78AB241")} {
+		request, response, err := c.PaidVerify(body)
+		if err != signNotMatchErr || request != nil || response.ReturnCode != "FAIL" {
+			t.Errorf("request: %+v, response: %+v, err: %v", request, response, err)
+		}
+	}
+}
+
+func TestClient_PaidVerifyLookupErrorMessage(t *testing.T) {
+	lookupErr := errors.New("dial tcp 10.0.0.1:3306: connection refused")
+	c, err := NewClient(testApiKey, "1900000109", WithOrderLookup(orderLookupFunc(func(outTradeNo string) (*OrderRecord, error) {
+		return nil, lookupErr
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, response, err := c.PaidVerify(signedNotifyBody(t, Map{
+		"return_code":  "SUCCESS",
+		"result_code":  "SUCCESS",
+		"mch_id":       "1900000109",
+		"out_trade_no": "1409811653",
+		"total_fee":    "100",
+	}))
+	if err != lookupErr || response.ReturnCode != "FAIL" || strings.Contains(response.ReturnMsg, "10.0.0.1") {
+		t.Errorf("response: %+v, err: %v", response, err)
+	}
 }
@@ -0,0 +1,259 @@
+package wxpay
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"sync"
+)
+
+// https://pay.weixin.qq.com/wiki/doc/api/tools/mch_pay.php?chapter=24_2
+
+const (
+	payBankUrl      = "/mmpaysptrans/pay_bank"
+	queryBankUrl    = "/mmpaysptrans/query_bank"
+	getPublicKeyUrl = "/risk/getpublickey"
+)
+
+const (
+	BankStatusProcessing = "PROCESSING" // 处理中
+	BankStatusSuccess    = "SUCCESS"    // 付款成功
+	BankStatusFailed     = "FAILED"     // 付款失败
+	BankStatusBankFail   = "BANK_FAIL"  // 银行退票，资金会退回商户
+)
+
+// PayBankRequest 收款方银行卡号和姓名会用RSA公钥加密后填入EncBankNo和EncTrueName
+type PayBankRequest struct {
+	XMLName        xml.Name `xml:"xml"`
+	MchID          string   `xml:"mch_id,omitempty"`
+	PartnerTradeNo string   `xml:"partner_trade_no,omitempty"` // 商户付款单号
+	NonceStr       string   `xml:"nonce_str,omitempty"`
+	Sign           string   `xml:"sign,omitempty"`
+	EncBankNo      string   `xml:"enc_bank_no,omitempty"`
+	EncTrueName    string   `xml:"enc_true_name,omitempty"`
+	BankCode       string   `xml:"bank_code,omitempty"` // 收款方开户行
+	Amount         int64    `xml:"amount,omitempty"`    // 单位分
+	Desc           string   `xml:"desc,omitempty"`      // 付款说明
+	BankNo         string   `xml:"-"`                   // 收款方银行卡号
+	TrueName       string   `xml:"-"`                   // 收款方用户名
+}
+
+type PayBankResponse struct {
+	Meta
+	MchID          string `xml:"mch_id"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	Amount         int64  `xml:"amount"`
+	NonceStr       string `xml:"nonce_str"`
+	PaymentNo      string `xml:"payment_no"` // 微信企业付款单号
+	CmmsAmt        int64  `xml:"cmms_amt"`   // 手续费，单位分
+}
+
+// PayBank 企业付款到银行卡，需要api证书。
+// 接口不会自动重试，结果未知时用QueryBank查询，确认付款单不存在后再用同一个partner_trade_no重新付款
+func (c *Client) PayBank(request *PayBankRequest) (*PayBankResponse, error) {
+	return c.PayBankContext(context.Background(), request)
+}
+
+func (c *Client) PayBankContext(ctx context.Context, request *PayBankRequest) (*PayBankResponse, error) {
+	request.MchID = c.mchId
+	request.NonceStr = nonceStr()
+
+	if len(request.PartnerTradeNo) == 0 {
+		return nil, errors.New("partner_trade_no is zero")
+	}
+	if len(request.BankNo) == 0 {
+		return nil, errors.New("bank_no is zero")
+	}
+	if len(request.TrueName) == 0 {
+		return nil, errors.New("true_name is zero")
+	}
+	if len(request.BankCode) == 0 {
+		return nil, errors.New("bank_code is zero")
+	}
+	if request.Amount <= 0 {
+		return nil, errors.New("amount is zero")
+	}
+
+	key, err := c.bankPublicKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if request.EncBankNo, err = encryptOAEP(key, request.BankNo); err != nil {
+		return nil, err
+	}
+	if request.EncTrueName, err = encryptOAEP(key, request.TrueName); err != nil {
+		return nil, err
+	}
+
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
+	var response PayBankResponse
+	_, err = c.request(ctx, payBankUrl, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+type QueryBankRequest struct {
+	XMLName        xml.Name `xml:"xml"`
+	MchID          string   `xml:"mch_id,omitempty"`
+	PartnerTradeNo string   `xml:"partner_trade_no,omitempty"`
+	NonceStr       string   `xml:"nonce_str,omitempty"`
+	Sign           string   `xml:"sign,omitempty"`
+}
+
+// QueryBankResponse 付款单不存在时ErrCode为NOT_FOUND
+type QueryBankResponse struct {
+	Meta
+	MchID          string `xml:"mch_id"`
+	PartnerTradeNo string `xml:"partner_trade_no"`
+	PaymentNo      string `xml:"payment_no"`
+	BankNoMd5      string `xml:"bank_no_md5"`   // 收款卡号的md5
+	TrueNameMd5    string `xml:"true_name_md5"` // 收款人姓名的md5
+	Amount         int64  `xml:"amount"`
+	Status         string `xml:"status"`        // PROCESSING/SUCCESS/FAILED/BANK_FAIL
+	CmmsAmt        int64  `xml:"cmms_amt"`      // 手续费，单位分
+	CreateTime     string `xml:"create_time"`   // 商户下单时间
+	PaySuccTime    string `xml:"pay_succ_time"` // 成功付款时间
+	Reason         string `xml:"reason"`        // 失败原因
+}
+
+// QueryBank 查询企业付款到银行卡的结果，需要api证书
+func (c *Client) QueryBank(request *QueryBankRequest) (*QueryBankResponse, error) {
+	return c.QueryBankContext(context.Background(), request)
+}
+
+func (c *Client) QueryBankContext(ctx context.Context, request *QueryBankRequest) (*QueryBankResponse, error) {
+	request.MchID = c.mchId
+	request.NonceStr = nonceStr()
+
+	if len(request.PartnerTradeNo) == 0 {
+		return nil, errors.New("partner_trade_no is zero")
+	}
+
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
+	var response QueryBankResponse
+	_, err = c.request(ctx, queryBankUrl, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// https://pay.weixin.qq.com/wiki/doc/api/tools/mch_pay.php?chapter=24_7
+
+type GetPublicKeyRequest struct {
+	XMLName  xml.Name `xml:"xml"`
+	MchID    string   `xml:"mch_id,omitempty"`
+	NonceStr string   `xml:"nonce_str,omitempty"`
+	Sign     string   `xml:"sign,omitempty"`
+	SignType string   `xml:"sign_type,omitempty"`
+}
+
+type GetPublicKeyResponse struct {
+	Meta
+	MchID  string `xml:"mch_id"`
+	PubKey string `xml:"pub_key"` // PKCS#1格式的PEM
+}
+
+// GetPublicKey 获取付款到银行卡加密用的RSA公钥，需要api证书，PayBank会自动获取并缓存
+func (c *Client) GetPublicKey() (*GetPublicKeyResponse, error) {
+	return c.GetPublicKeyContext(context.Background())
+}
+
+func (c *Client) GetPublicKeyContext(ctx context.Context) (*GetPublicKeyResponse, error) {
+	request := &GetPublicKeyRequest{
+		MchID:    c.mchId,
+		NonceStr: nonceStr(),
+		SignType: SignTypeMD5,
+	}
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
+	var response GetPublicKeyResponse
+	_, err = c.request(ctx, getPublicKeyUrl, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+type bankKey struct {
+	mu  sync.Mutex
+	key *rsa.PublicKey
+}
+
+// WithBankPublicKey 使用已经保存的RSA公钥，不再调用getpublickey接口
+func WithBankPublicKey(pemBytes []byte) Option {
+	return func(c *Client) error {
+		key, err := parsePublicKey(pemBytes)
+		if err != nil {
+			return err
+		}
+		c.bankKey = &bankKey{key: key}
+		return nil
+	}
+}
+
+// 首次调用时获取并缓存RSA公钥
+func (c *Client) bankPublicKey(ctx context.Context) (*rsa.PublicKey, error) {
+	c.bankKey.mu.Lock()
+	defer c.bankKey.mu.Unlock()
+	if c.bankKey.key != nil {
+		return c.bankKey.key, nil
+	}
+
+	response, err := c.GetPublicKeyContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := response.Err(); err != nil {
+		return nil, err
+	}
+	key, err := parsePublicKey([]byte(response.PubKey))
+	if err != nil {
+		return nil, err
+	}
+	c.bankKey.key = key
+	return key, nil
+}
+
+func parsePublicKey(pemBytes []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("invalid public key pem")
+	}
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not rsa")
+	}
+	return rsaKey, nil
+}
+
+// RSA-OAEP(SHA1)加密后base64编码
+func encryptOAEP(key *rsa.PublicKey, plainText string) (string, error) {
+	cipherText, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, key, []byte(plainText), nil)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(cipherText), nil
+}
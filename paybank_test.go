@@ -0,0 +1,148 @@
+package wxpay
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decryptOAEP(t *testing.T, key *rsa.PrivateKey, s string) string {
+	cipherText, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plainText, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, cipherText, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(plainText)
+}
+
+func TestClient_PayBank(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+
+	var keyCalls int
+	risk := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		keyCalls++
+		if path != getPublicKeyUrl || req["sign_type"] != SignTypeMD5 {
+			t.Errorf("request: %s %v", path, req)
+		}
+		return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "mch_id": req["mch_id"], "pub_key": string(pubKey)}
+	})
+	defer risk.Close()
+
+	api := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		switch path {
+		case payBankUrl:
+			if bankNo := decryptOAEP(t, key, req["enc_bank_no"]); bankNo != "6225760000000000" {
+				t.Errorf("bank_no: %s", bankNo)
+			}
+			if trueName := decryptOAEP(t, key, req["enc_true_name"]); trueName != "张三" {
+				t.Errorf("true_name: %s", trueName)
+			}
+			if req["amount"] != "500" || req["bank_code"] != "1001" {
+				t.Errorf("request: %v", req)
+			}
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "partner_trade_no": req["partner_trade_no"], "payment_no": "10000600500852017030900000020006012", "cmms_amt": "1"}
+		case queryBankUrl:
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "partner_trade_no": req["partner_trade_no"], "status": BankStatusProcessing}
+		default:
+			t.Errorf("unexpected path: %s", path)
+			return Map{}
+		}
+	})
+	defer api.Close()
+
+	c, err := NewClient(testApiKey, "10000100", WithBaseUrl(api.URL), WithRiskBaseUrl(risk.URL), WithTransport(http.DefaultTransport), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := c.PayBank(&PayBankRequest{
+			PartnerTradeNo: "1212121221227",
+			BankNo:         "6225760000000000",
+			TrueName:       "张三",
+			BankCode:       "1001",
+			Amount:         500,
+			Desc:           "货款",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.ResultCodeSuccess() || resp.CmmsAmt != 1 {
+			t.Errorf("response: %+v", resp)
+		}
+	}
+	if keyCalls != 1 {
+		t.Errorf("getpublickey called %d times", keyCalls)
+	}
+
+	query, err := c.QueryBank(&QueryBankRequest{PartnerTradeNo: "1212121221227"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Status != BankStatusProcessing {
+		t.Errorf("query: %+v", query)
+	}
+
+	// 使用保存的公钥时不调用getpublickey
+	c, err = NewClient(testApiKey, "10000100", WithBaseUrl(api.URL), WithRiskBaseUrl(risk.URL), WithTransport(http.DefaultTransport), WithBankPublicKey(pubKey), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PayBank(&PayBankRequest{PartnerTradeNo: "1212121221228", BankNo: "6225760000000000", TrueName: "张三", BankCode: "1001", Amount: 500}); err != nil {
+		t.Fatal(err)
+	}
+	if keyCalls != 1 {
+		t.Errorf("getpublickey called %d times", keyCalls)
+	}
+}
+
+func TestClient_PayBankRequiresCert(t *testing.T) {
+	c := New(testApiKey, "10000100")
+	_, err := c.QueryBank(&QueryBankRequest{PartnerTradeNo: "1212121221227"})
+	if err != certNotSetErr {
+		t.Errorf("err: %v", err)
+	}
+}
+
+func TestClient_PayBankCheckSign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&key.PublicKey)})
+
+	for _, path := range []string{payBankUrl, transferInfoURL} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 签名后篡改了payment_no
+			w.Write([]byte(`<xml><return_code>SUCCESS</return_code><result_code>SUCCESS</result_code>` +
+				`<partner_trade_no>1212121221227</partner_trade_no><payment_no>forged</payment_no>` +
+				`<sign>B552ED6B279343CB493C5DD0D78AB241</sign></xml>`))
+		}))
+
+		c, err := NewClient(testApiKey, "10000100", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport), WithBankPublicKey(pubKey), withTestCert(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if path == payBankUrl {
+			_, err = c.PayBank(&PayBankRequest{PartnerTradeNo: "1212121221227", BankNo: "6225760000000000", TrueName: "张三", BankCode: "1001", Amount: 500})
+		} else {
+			_, err = c.GetTransferInfo(&TransferInfoRequest{PartnerTradeNo: "1212121221227"})
+		}
+		if err != signNotMatchErr {
+			t.Errorf("%s err: %v", path, err)
+		}
+		server.Close()
+	}
+}
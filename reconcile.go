@@ -0,0 +1,264 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// LocalOrder 本地系统中的一笔订单
+type LocalOrder struct {
+	OutTradeNo string
+	TotalFee   int64 // 订单金额，单位为分
+	Paid       bool  // 本地是否已确认支付
+}
+
+// LocalRefund 本地系统中的一笔退款
+type LocalRefund struct {
+	OutRefundNo string
+	OutTradeNo  string
+	RefundFee   int64 // 申请退款金额，单位为分
+}
+
+// ReconcileSource 提供对账日的本地订单和退款，按交易时间（北京时间）划分对账日
+type ReconcileSource interface {
+	Orders(ctx context.Context, billDate string) ([]*LocalOrder, error)
+	Refunds(ctx context.Context, billDate string) ([]*LocalRefund, error)
+}
+
+type DiffType string
+
+const (
+	DiffMissingInLocal     DiffType = "MISSING_IN_LOCAL"    // 账单中有，本地没有
+	DiffMissingInBill      DiffType = "MISSING_IN_BILL"     // 本地有，账单中没有
+	DiffAmountMismatch     DiffType = "AMOUNT_MISMATCH"     // 金额不一致
+	DiffStatusMismatch     DiffType = "STATUS_MISMATCH"     // 状态不一致，例如账单中已撤销而本地已支付
+	DiffStatisticsMismatch DiffType = "STATISTICS_MISMATCH" // 账单明细的合计与统计数据不一致
+)
+
+type Difference struct {
+	Type          DiffType `json:"type"`
+	OutTradeNo    string   `json:"out_trade_no,omitempty"`
+	OutRefundNo   string   `json:"out_refund_no,omitempty"`
+	TransactionId string   `json:"transaction_id,omitempty"`
+	Field         string   `json:"field,omitempty"` // 不一致的字段
+	BillValue     string   `json:"bill_value,omitempty"`
+	LocalValue    string   `json:"local_value,omitempty"`
+}
+
+type ReconcileReport struct {
+	BillDate    string        `json:"bill_date"`
+	Matched     int           `json:"matched"` // 核对一致的记录数
+	Differences []*Difference `json:"differences"`
+}
+
+// Reconcile 流式下载billDate的全部订单账单，与source提供的本地订单和退款逐笔核对，
+// 并用账单明细的合计核对账单末尾的统计数据。当天没有账单时所有本地已支付订单都记为账单中缺失
+func (c *Client) Reconcile(ctx context.Context, billDate string, source ReconcileSource) (*ReconcileReport, error) {
+	orders, err := source.Orders(ctx, billDate)
+	if err != nil {
+		return nil, err
+	}
+	refunds, err := source.Refunds(ctx, billDate)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reconciler{
+		report: &ReconcileReport{
+			BillDate:    billDate,
+			Differences: make([]*Difference, 0),
+		},
+		orders:  make(map[string]*LocalOrder, len(orders)),
+		refunds: make(map[string]*LocalRefund, len(refunds)),
+		seen:    make(map[string]bool),
+	}
+	for _, order := range orders {
+		r.orders[order.OutTradeNo] = order
+	}
+	for _, refund := range refunds {
+		r.refunds[refund.OutRefundNo] = refund
+	}
+
+	request := &DownloadBillRequest{
+		BillDate: billDate,
+		BillType: BillTypeAll,
+	}
+	statistics, err := c.DownloadBillStream(ctx, request, r.entry)
+	if err != nil && !IsBillNoExist(err) {
+		return nil, err
+	}
+
+	r.missingInBill(orders, refunds)
+	r.statistics(statistics)
+	return r.report, nil
+}
+
+type reconciler struct {
+	report  *ReconcileReport
+	orders  map[string]*LocalOrder  // out_trade_no
+	refunds map[string]*LocalRefund // out_refund_no
+	seen    map[string]bool         // 账单中出现过的支付订单和退款单
+
+	// 账单明细的合计
+	count          int64
+	totalFee       Fen
+	refundFee      Fen
+	handlingCharge Fen
+}
+
+func (r *reconciler) entry(entry *BillEntry) error {
+	r.count++
+	r.totalFee += entry.TotalFee
+	r.refundFee += entry.RefundFee
+	r.handlingCharge += entry.HandlingCharge
+
+	if entry.TradeStatus == BillTradeStatusRefund {
+		r.refund(entry)
+	} else {
+		r.order(entry)
+	}
+	return nil
+}
+
+func (r *reconciler) order(entry *BillEntry) {
+	r.seen["order:"+entry.OutTradeNo] = true
+	diff := &Difference{
+		OutTradeNo:    entry.OutTradeNo,
+		TransactionId: entry.TransactionId,
+	}
+
+	order, ok := r.orders[entry.OutTradeNo]
+	if !ok {
+		diff.Type = DiffMissingInLocal
+		r.add(diff)
+		return
+	}
+
+	paid := entry.TradeStatus == BillTradeStatusSuccess
+	if paid != order.Paid {
+		diff.Type = DiffStatusMismatch
+		diff.Field = "trade_status"
+		diff.BillValue = entry.TradeStatus
+		diff.LocalValue = "paid=" + strconv.FormatBool(order.Paid)
+		r.add(diff)
+		return
+	}
+
+	// 新版账单的订单金额包含代金券，旧版账单只有总金额
+	fee := entry.OrderFee
+	if fee == 0 {
+		fee = entry.TotalFee
+	}
+	if paid && int64(fee) != order.TotalFee {
+		diff.Type = DiffAmountMismatch
+		diff.Field = "total_fee"
+		diff.BillValue = fee.String()
+		diff.LocalValue = Fen(order.TotalFee).String()
+		r.add(diff)
+		return
+	}
+	r.report.Matched++
+}
+
+func (r *reconciler) refund(entry *BillEntry) {
+	r.seen["refund:"+entry.OutRefundNo] = true
+	diff := &Difference{
+		OutTradeNo:    entry.OutTradeNo,
+		OutRefundNo:   entry.OutRefundNo,
+		TransactionId: entry.TransactionId,
+	}
+
+	refund, ok := r.refunds[entry.OutRefundNo]
+	if !ok {
+		diff.Type = DiffMissingInLocal
+		r.add(diff)
+		return
+	}
+
+	fee := entry.ApplyRefundFee
+	if fee == 0 {
+		fee = entry.RefundFee
+	}
+	if int64(fee) != refund.RefundFee {
+		diff.Type = DiffAmountMismatch
+		diff.Field = "refund_fee"
+		diff.BillValue = fee.String()
+		diff.LocalValue = Fen(refund.RefundFee).String()
+		r.add(diff)
+		return
+	}
+	r.report.Matched++
+}
+
+// 本地已支付的订单和本地的退款都应该出现在账单中
+func (r *reconciler) missingInBill(orders []*LocalOrder, refunds []*LocalRefund) {
+	for _, order := range orders {
+		if order.Paid && !r.seen["order:"+order.OutTradeNo] {
+			r.add(&Difference{
+				Type:       DiffMissingInBill,
+				OutTradeNo: order.OutTradeNo,
+				LocalValue: Fen(order.TotalFee).String(),
+			})
+		}
+	}
+	for _, refund := range refunds {
+		if !r.seen["refund:"+refund.OutRefundNo] {
+			r.add(&Difference{
+				Type:        DiffMissingInBill,
+				OutTradeNo:  refund.OutTradeNo,
+				OutRefundNo: refund.OutRefundNo,
+				LocalValue:  Fen(refund.RefundFee).String(),
+			})
+		}
+	}
+}
+
+func (r *reconciler) statistics(statistics []*BillStatistics) {
+	if len(statistics) == 0 {
+		return
+	}
+	s := statistics[0]
+	// 字段名与BillStatistics的json tag一致
+	r.compare("trade_order_count", strconv.FormatInt(s.TradeOrderCount, 10), strconv.FormatInt(r.count, 10))
+	r.compare("total_business_transaction", s.TotalBusinessTransaction.String(), r.totalFee.String())
+	r.compare("total_refund_fee", s.TotalRefundFee.String(), r.refundFee.String())
+	r.compare("total_handling_charge", s.TotalHandlingCharge.String(), r.handlingCharge.String())
+}
+
+// 统计数据与明细的合计比较，LocalValue为明细的合计
+func (r *reconciler) compare(field, statistics, sum string) {
+	if statistics == sum {
+		return
+	}
+	r.add(&Difference{
+		Type:       DiffStatisticsMismatch,
+		Field:      field,
+		BillValue:  statistics,
+		LocalValue: sum,
+	})
+}
+
+func (r *reconciler) add(diff *Difference) {
+	r.report.Differences = append(r.report.Differences, diff)
+}
+
+// WriteJSON 以JSON格式输出对账结果
+func (r *ReconcileReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// WriteCSV 以CSV格式输出不一致的记录，每条差异一行
+func (r *ReconcileReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"bill_date", "type", "out_trade_no", "out_refund_no", "transaction_id", "field", "bill_value", "local_value"})
+	for _, diff := range r.Differences {
+		writer.Write([]string{r.BillDate, string(diff.Type), diff.OutTradeNo, diff.OutRefundNo, diff.TransactionId, diff.Field, diff.BillValue, diff.LocalValue})
+	}
+	writer.Flush()
+	return writer.Error()
+}
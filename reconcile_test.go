@@ -0,0 +1,103 @@
+package wxpay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const reconcileBill = "交易时间,微信订单号,商户订单号,交易状态,应结订单金额,微信退款单号,商户退款单号,退款金额,手续费,订单金额,申请退款金额\r\n" +
+	"`2018-05-02 10:00:00,`4200000001,`A,`SUCCESS,`1.00,`0,`0,`0.00,`0.01,`1.00,`0.00\r\n" +
+	"`2018-05-02 11:00:00,`4200000002,`B,`SUCCESS,`2.00,`0,`0,`0.00,`0.01,`2.00,`0.00\r\n" +
+	"`2018-05-02 12:00:00,`4200000003,`C,`REVOKED,`3.00,`0,`0,`0.00,`0.00,`3.00,`0.00\r\n" +
+	"`2018-05-02 13:00:00,`4200000004,`D,`SUCCESS,`0.50,`0,`0,`0.00,`0.01,`0.50,`0.00\r\n" +
+	"`2018-05-02 14:00:00,`4200000001,`A,`REFUND,`0.00,`5000001,`R1,`1.00,`0.00,`0.00,`1.00\r\n" +
+	"总交易单数,应结订单总金额,退款总金额,充值券退款总金额,手续费总金额\r\n" +
+	"`5,`6.50,`1.00,`0.00,`0.04\r\n"
+
+type reconcileSource struct {
+	orders  []*LocalOrder
+	refunds []*LocalRefund
+}
+
+func (s *reconcileSource) Orders(ctx context.Context, billDate string) ([]*LocalOrder, error) {
+	return s.orders, nil
+}
+
+func (s *reconcileSource) Refunds(ctx context.Context, billDate string) ([]*LocalRefund, error) {
+	return s.refunds, nil
+}
+
+func TestClient_Reconcile(t *testing.T) {
+	server := newBillServer(t, func(path string, req Map) string {
+		if req["bill_date"] != "20180502" || req["bill_type"] != BillTypeAll {
+			t.Errorf("request: %v", req)
+		}
+		return reconcileBill
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "10000100", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &reconcileSource{
+		orders: []*LocalOrder{
+			{OutTradeNo: "A", TotalFee: 100, Paid: true},
+			{OutTradeNo: "B", TotalFee: 150, Paid: true},
+			{OutTradeNo: "C", TotalFee: 300, Paid: true},
+			{OutTradeNo: "E", TotalFee: 800, Paid: true},
+			{OutTradeNo: "F", TotalFee: 900, Paid: false},
+		},
+		refunds: []*LocalRefund{
+			{OutRefundNo: "R1", OutTradeNo: "A", RefundFee: 100},
+			{OutRefundNo: "R2", OutTradeNo: "B", RefundFee: 50},
+		},
+	}
+	report, err := c.Reconcile(context.Background(), "20180502", source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Matched != 2 {
+		t.Errorf("matched: %d", report.Matched)
+	}
+
+	want := []string{
+		"AMOUNT_MISMATCH B  total_fee 2.00 1.50",
+		"STATUS_MISMATCH C  trade_status REVOKED paid=true",
+		"MISSING_IN_LOCAL D    ",
+		"MISSING_IN_BILL E    8.00",
+		"MISSING_IN_BILL B R2   0.50",
+		"STATISTICS_MISMATCH   total_handling_charge 0.04 0.03",
+	}
+	if len(report.Differences) != len(want) {
+		t.Fatalf("differences: %d", len(report.Differences))
+	}
+	for i, diff := range report.Differences {
+		got := strings.Join([]string{string(diff.Type), diff.OutTradeNo, diff.OutRefundNo, diff.Field, diff.BillValue, diff.LocalValue}, " ")
+		if got != want[i] {
+			t.Errorf("difference %d: %q, want %q", i, got, want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != len(want)+1 ||
+		lines[1] != "20180502,AMOUNT_MISMATCH,B,,4200000002,total_fee,2.00,1.50" {
+		t.Errorf("csv: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded ReconcileReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.BillDate != "20180502" || len(decoded.Differences) != len(want) {
+		t.Errorf("json: %s, err: %v", buf.String(), err)
+	}
+}
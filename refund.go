@@ -1,17 +1,22 @@
 package wxpay
 
-import "encoding/xml"
+import (
+	"context"
+	"encoding/xml"
+)
 
 // https://pay.weixin.qq.com/wiki/doc/api/native.php?chapter=9_4
 
 const (
-	refundUrl = "https://api.mch.weixin.qq.com/secapi/pay/refund"
+	refundUrl = "/secapi/pay/refund"
 )
 
 type RefundRequest struct {
 	XMLName       xml.Name `xml:"xml"`
 	AppId         string   `xml:"appid,omitempty"`
 	MchId         string   `xml:"mch_id,omitempty"`
+	SubAppId      string   `xml:"sub_appid,omitempty"`
+	SubMchId      string   `xml:"sub_mch_id,omitempty"`
 	NonceStr      string   `xml:"nonce_str,omitempty"`
 	Sign          string   `xml:"sign,omitempty"`
 	SignType      string   `xml:"sign_type,omitempty"`
@@ -28,34 +33,48 @@ type RefundRequest struct {
 
 type RefundResponse struct {
 	Meta
-	AppId               string `xml:"appid"`
-	MchId               string `xml:"mch_id"`
-	NonceStr            string `xml:"nonce_str"`
-	Sign                string `xml:"sign"`
-	TransactionId       string `xml:"transaction_id"`
-	OutTradeNo          string `xml:"out_trade_no"`
-	OutRefundNo         string `xml:"out_refund_no"`
-	RefundId            string `xml:"refund_id"`
-	RefundFee           int64  `xml:"refund_fee"`
-	SettlementRefundFee int64  `xml:"settlement_refund_fee"`
-	TotalFee            int64  `xml:"total_fee"`
-	SettlementTotalFee  int64  `xml:"settlement_total_fee"`
-	FeeType             string `xml:"fee_type"`
-	CashFee             int64  `xml:"cash_fee"`
-	CashFeeType         string `xml:"cash_fee_type"`
-	CashRefundFee       int64  `xml:"cash_refund_fee"`
-	CouponRefundFee     int64  `xml:"coupon_refund_fee"`
-	CouponRefundCount   int    `xml:"coupon_refund_count"`
+	AppId               string          `xml:"appid"`
+	MchId               string          `xml:"mch_id"`
+	SubAppId            string          `xml:"sub_appid"`
+	SubMchId            string          `xml:"sub_mch_id"`
+	NonceStr            string          `xml:"nonce_str"`
+	Sign                string          `xml:"sign"`
+	TransactionId       string          `xml:"transaction_id"`
+	OutTradeNo          string          `xml:"out_trade_no"`
+	OutRefundNo         string          `xml:"out_refund_no"`
+	RefundId            string          `xml:"refund_id"`
+	RefundFee           int64           `xml:"refund_fee"`
+	SettlementRefundFee int64           `xml:"settlement_refund_fee"`
+	TotalFee            int64           `xml:"total_fee"`
+	SettlementTotalFee  int64           `xml:"settlement_total_fee"`
+	FeeType             string          `xml:"fee_type"`
+	CashFee             int64           `xml:"cash_fee"`
+	CashFeeType         string          `xml:"cash_fee_type"`
+	CashRefundFee       int64           `xml:"cash_refund_fee"`
+	CouponRefundFee     int64           `xml:"coupon_refund_fee"`
+	CouponRefundCount   int             `xml:"coupon_refund_count"`
+	Coupons             []*RefundCoupon `xml:"-"`
 }
 
 func (c *Client) Refund(request *RefundRequest) (*RefundResponse, error) {
+	return c.RefundContext(context.Background(), request)
+}
+
+func (c *Client) RefundContext(ctx context.Context, request *RefundRequest) (*RefundResponse, error) {
 	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
 	request.NonceStr = nonceStr()
-	request.Sign = signStruct(request, c.apiKey)
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response RefundResponse
-	_, err := c.request(refundUrl, request, &response)
+	body, err := c.request(ctx, refundUrl, request, &response)
 	if err != nil {
 		return nil, err
 	}
+	if err := unmarshalIndexed(body, &response); err != nil {
+		return nil, err
+	}
 	return &response, nil
 }
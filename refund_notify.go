@@ -0,0 +1,130 @@
+package wxpay
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=9_16&index=10
+
+type RefundNotifyRequest struct {
+	XMLName       xml.Name `xml:"xml"`
+	ReturnCode    string   `xml:"return_code"`
+	ReturnMsg     string   `xml:"return_msg"`
+	AppId         string   `xml:"appid"`
+	MchId         string   `xml:"mch_id"`
+	SubAppId      string   `xml:"sub_appid"`
+	SubMchId      string   `xml:"sub_mch_id"`
+	NonceStr      string   `xml:"nonce_str"`
+	ReqInfo       string   `xml:"req_info"` // 加密信息，解密后的内容见RefundReqInfo
+	RefundReqInfo `xml:"-"`
+}
+
+type RefundReqInfo struct {
+	XMLName             xml.Name `xml:"root"`
+	TransactionId       string   `xml:"transaction_id"`        // 微信订单号
+	OutTradeNo          string   `xml:"out_trade_no"`          // 商户订单号
+	RefundId            string   `xml:"refund_id"`             // 微信退款单号
+	OutRefundNo         string   `xml:"out_refund_no"`         // 商户退款单号
+	TotalFee            int64    `xml:"total_fee"`             // 订单金额
+	SettlementTotalFee  int64    `xml:"settlement_total_fee"`  // 应结订单金额
+	RefundFee           int64    `xml:"refund_fee"`            // 申请退款金额
+	SettlementRefundFee int64    `xml:"settlement_refund_fee"` // 退款金额
+	RefundStatus        string   `xml:"refund_status"`         // 退款状态 SUCCESS/CHANGE/REFUNDCLOSE
+	SuccessTime         string   `xml:"success_time"`          // 退款成功时间
+	RefundRecvAccount   string   `xml:"refund_recv_accout"`    // 退款入账账户，注意这里是微信的拼写错误
+	RefundAccount       string   `xml:"refund_account"`        // 退款资金来源
+	RefundRequestSource string   `xml:"refund_request_source"` // 退款发起来源
+}
+
+type RefundNotifyResponse struct {
+	XMLName    xml.Name `xml:"xml"`
+	ReturnCode string   `xml:"return_code"`
+	ReturnMsg  string   `xml:"return_msg"`
+}
+
+// RefundVerify 校验退款结果通知，退款结果通知没有签名，req_info使用商户key的md5做密钥进行AES-256-ECB解密。
+// 校验失败时返回return_code为FAIL的应答和error
+func (c *Client) RefundVerify(body []byte) (*RefundNotifyRequest, *RefundNotifyResponse, error) {
+	var notifyRequest RefundNotifyRequest
+	err := xml.Unmarshal(body, &notifyRequest)
+	if err != nil {
+		return nil, failRefundNotifyResponse("参数格式校验错误"), err
+	}
+
+	if notifyRequest.ReturnCode != success {
+		return nil, failRefundNotifyResponse("通信结果不成功"), errors.New("通信结果不成功: " + notifyRequest.ReturnMsg)
+	}
+
+	if notifyRequest.MchId != c.mchId || c.subMchId != "" && notifyRequest.SubMchId != c.subMchId {
+		return nil, failRefundNotifyResponse("商户号不匹配"), mchIdNotMatchErr
+	}
+
+	reqInfo, err := decryptReqInfo(notifyRequest.ReqInfo, c.apiKey)
+	if err != nil {
+		notifyAsync(string(body), err)
+		return nil, failRefundNotifyResponse("解密失败"), err
+	}
+	globalLogger.printf("req_info: %s", string(reqInfo))
+
+	if err := xml.Unmarshal(reqInfo, &notifyRequest.RefundReqInfo); err != nil {
+		return nil, failRefundNotifyResponse("参数格式校验错误"), err
+	}
+
+	return &notifyRequest, &RefundNotifyResponse{ReturnCode: success, ReturnMsg: "OK"}, nil
+}
+
+func (c *Client) RefundNotifyVerify(request *http.Request) (*RefundNotifyRequest, *RefundNotifyResponse, error) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		return nil, failRefundNotifyResponse("读取通知失败"), err
+	}
+	globalLogger.printf("%s: %s", request.URL.String(), string(body))
+	return c.RefundVerify(body)
+}
+
+func failRefundNotifyResponse(msg string) *RefundNotifyResponse {
+	return &RefundNotifyResponse{
+		ReturnCode: fail,
+		ReturnMsg:  msg,
+	}
+}
+
+func decryptReqInfo(reqInfo string, apiKey string) ([]byte, error) {
+	cipherText, err := base64.StdEncoding.DecodeString(reqInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum([]byte(apiKey))
+	block, err := aes.NewCipher([]byte(hex.EncodeToString(sum[:])))
+	if err != nil {
+		return nil, err
+	}
+
+	blockSize := block.BlockSize()
+	if len(cipherText) == 0 || len(cipherText)%blockSize != 0 {
+		return nil, errors.New("req_info is not a multiple of the block size")
+	}
+
+	// ECB模式，逐块解密
+	plainText := make([]byte, len(cipherText))
+	for i := 0; i < len(cipherText); i += blockSize {
+		block.Decrypt(plainText[i:i+blockSize], cipherText[i:i+blockSize])
+	}
+
+	// PKCS7
+	padding := int(plainText[len(plainText)-1])
+	if padding == 0 || padding > blockSize ||
+		!bytes.Equal(plainText[len(plainText)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("req_info has invalid padding")
+	}
+	return plainText[:len(plainText)-padding], nil
+}
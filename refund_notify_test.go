@@ -0,0 +1,93 @@
+package wxpay
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+var refundReqInfo = []byte(
+	`<root>
+<out_refund_no><![CDATA[131811191610442717309]]></out_refund_no>
+<out_trade_no><![CDATA[71106718111915575302817]]></out_trade_no>
+<refund_account><![CDATA[REFUND_SOURCE_RECHARGE_FUNDS]]></refund_account>
+<refund_fee><![CDATA[3960]]></refund_fee>
+<refund_id><![CDATA[50000408942018111907145868882]]></refund_id>
+<refund_recv_accout><![CDATA[支付用户零钱]]></refund_recv_accout>
+<refund_request_source><![CDATA[API]]></refund_request_source>
+<refund_status><![CDATA[SUCCESS]]></refund_status>
+<settlement_refund_fee><![CDATA[3960]]></settlement_refund_fee>
+<settlement_total_fee><![CDATA[3960]]></settlement_total_fee>
+<success_time><![CDATA[2018-11-19 16:24:13]]></success_time>
+<total_fee><![CDATA[3960]]></total_fee>
+<transaction_id><![CDATA[4200000215201811190261405420]]></transaction_id>
+</root>`,
+)
+
+// AES-256-ECB加密，PKCS7填充
+func encryptReqInfo(t *testing.T, plainText []byte, apiKey string) string {
+	sum := md5.Sum([]byte(apiKey))
+	block, err := aes.NewCipher([]byte(hex.EncodeToString(sum[:])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plainText)%aes.BlockSize
+	plainText = append(plainText, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipherText := make([]byte, len(plainText))
+	for i := 0; i < len(plainText); i += aes.BlockSize {
+		block.Encrypt(cipherText[i:i+aes.BlockSize], plainText[i:i+aes.BlockSize])
+	}
+	return base64.StdEncoding.EncodeToString(cipherText)
+}
+
+func TestClient_RefundVerify(t *testing.T) {
+	body := []byte(`<xml>
+<return_code>SUCCESS</return_code>
+<appid><![CDATA[wx2421b1c4370ec43b]]></appid>
+<mch_id><![CDATA[10000100]]></mch_id>
+<nonce_str><![CDATA[TeqClE3i0mvn3DrK]]></nonce_str>
+<req_info><![CDATA[` + encryptReqInfo(t, refundReqInfo, testApiKey) + `]]></req_info>
+</xml>`)
+
+	c := New(testApiKey, "10000100")
+	request, response, err := c.RefundVerify(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.RefundStatus != RefundStatusSuccess ||
+		request.RefundFee != 3960 ||
+		request.OutRefundNo != "131811191610442717309" ||
+		request.SuccessTime != "2018-11-19 16:24:13" ||
+		request.MchId != "10000100" {
+		t.Errorf("request: %+v", request)
+	}
+	if response.ReturnCode != "SUCCESS" {
+		t.Errorf("response: %+v", response)
+	}
+
+	tests := []struct {
+		name   string
+		client *Client
+		body   []byte
+		err    error
+	}{
+		{"wrong key", New("wrong key", "10000100"), body, nil},
+		{"wrong mch_id", New(testApiKey, "10000101"), body, mchIdNotMatchErr},
+		{"wrong sub_mch_id", New(testApiKey, "10000100").SubMerchant("", "1900000109"), body, mchIdNotMatchErr},
+		{"return_code FAIL", c, []byte(`<xml><return_code>FAIL</return_code><return_msg>err</return_msg></xml>`), nil},
+		{"malformed", c, []byte(`<xml>`), nil},
+	}
+	for _, test := range tests {
+		request, response, err := test.client.RefundVerify(test.body)
+		if err == nil || test.err != nil && err != test.err {
+			t.Errorf("%s: err: %v", test.name, err)
+		}
+		// 校验失败时同样返回应答给微信
+		if request != nil || response == nil || response.ReturnCode != fail {
+			t.Errorf("%s: request: %+v, response: %+v", test.name, request, response)
+		}
+	}
+}
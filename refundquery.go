@@ -1,13 +1,13 @@
 package wxpay
 
 import (
+	"context"
 	"encoding/xml"
-	"strconv"
 )
 
 // https://pay.weixin.qq.com/wiki/doc/api/native.php?chapter=9_5
 const (
-	refundQueryUrl = "https://api.mch.weixin.qq.com/pay/refundquery"
+	refundQueryUrl = "/pay/refundquery"
 )
 
 const (
@@ -21,6 +21,8 @@ type RefundQueryRequest struct {
 	XMLName       xml.Name `xml:"xml"`
 	AppId         string   `xml:"appid,omitempty"`
 	MchId         string   `xml:"mch_id,omitempty"`
+	SubAppId      string   `xml:"sub_appid,omitempty"`
+	SubMchId      string   `xml:"sub_mch_id,omitempty"`
 	NonceStr      string   `xml:"nonce_str,omitempty"`
 	Sign          string   `xml:"sign,omitempty"`
 	SignType      string   `xml:"sign_type,omitempty"`
@@ -32,23 +34,26 @@ type RefundQueryRequest struct {
 }
 
 type RefundDetail struct {
-	OutRefundNo         string // 商户退款单号
-	RefundId            string // 微信退款单号
-	RefundChannel       string // 退款渠道
-	RefundFee           int64  // 申请退款金额
-	SettlementRefundFee int64  // 退款金额
-	CouponRefundFee     int64  // 总代金券退款金额
-	CouponRefundCount   int    // 退款代金券使用数量
-	RefundStatus        string // 退款状态
-	RefundAccount       string // 退款资金来源
-	RefundRecvAccount   string // 退款入账账户
-	RefundSuccessTime   string // 退款成功时间
+	OutRefundNo         string                `xml:"out_refund_no_$n"`         // 商户退款单号
+	RefundId            string                `xml:"refund_id_$n"`             // 微信退款单号
+	RefundChannel       string                `xml:"refund_channel_$n"`        // 退款渠道
+	RefundFee           int64                 `xml:"refund_fee_$n"`            // 申请退款金额
+	SettlementRefundFee int64                 `xml:"settlement_refund_fee_$n"` // 退款金额
+	CouponRefundFee     int64                 `xml:"coupon_refund_fee_$n"`     // 总代金券退款金额
+	CouponRefundCount   int                   `xml:"coupon_refund_count_$n"`   // 退款代金券使用数量
+	Coupons             []*RefundDetailCoupon `xml:"-"`                        // 代金券退款明细
+	RefundStatus        string                `xml:"refund_status_$n"`         // 退款状态
+	RefundAccount       string                `xml:"refund_account_$n"`        // 退款资金来源
+	RefundRecvAccount   string                `xml:"refund_recv_accout_$n"`    // 退款入账账户，注意这里是微信的拼写错误
+	RefundSuccessTime   string                `xml:"refund_success_time_$n"`   // 退款成功时间
 }
 
 type RefundQueryResponse struct {
 	Meta
 	AppId              string          `xml:"appid"`
 	MchId              string          `xml:"mch_id"`
+	SubAppId           string          `xml:"sub_appid"`
+	SubMchId           string          `xml:"sub_mch_id"`
 	NonceStr           string          `xml:"nonce_str"`
 	Sign               string          `xml:"sign"`
 	TotalRefundCount   int             `xml:"total_refund_count"` // 订单总退款次数, 订单总共已发生的部分退款次数，当请求参数传入offset后有返回
@@ -63,86 +68,25 @@ type RefundQueryResponse struct {
 }
 
 func (c *Client) RefundQuery(request *RefundQueryRequest) (*RefundQueryResponse, error) {
+	return c.RefundQueryContext(context.Background(), request)
+}
+
+func (c *Client) RefundQueryContext(ctx context.Context, request *RefundQueryRequest) (*RefundQueryResponse, error) {
 	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
 	request.NonceStr = nonceStr()
-	request.Sign = signStruct(request, c.apiKey)
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response RefundQueryResponse
-	body, err := c.request(refundQueryUrl, request, &response)
+	body, err := c.request(ctx, refundQueryUrl, request, &response)
 	if err != nil {
 		return nil, err
 	}
-	tempMap := make(Map)
-	if err := xml.Unmarshal(body, &tempMap); err != nil {
+	if err := unmarshalIndexed(body, &response); err != nil {
 		return nil, err
 	}
 
-	response.RefundDetails = make([]*RefundDetail, 0, response.RefundCount)
-	for i := 0; i < response.RefundCount; i++ {
-
-		rd := new(RefundDetail)
-		response.RefundDetails = append(response.RefundDetails, rd)
-
-		index := strconv.Itoa(i)
-		key := "out_refund_no_" + index
-		if val, ok := tempMap[key]; ok {
-			rd.OutRefundNo = val
-		}
-
-		key = "refund_id_" + index
-		if val, ok := tempMap[key]; ok {
-			rd.RefundId = val
-		}
-
-		key = "refund_channel_" + index
-		if val, ok := tempMap[key]; ok {
-			rd.RefundChannel = val
-		}
-
-		key = "refund_fee_" + index
-		if val, ok := tempMap[key]; ok {
-			intVal, _ := strconv.ParseInt(val, 10, 0)
-			rd.RefundFee = intVal
-		}
-
-		key = "settlement_refund_fee_" + index
-		if val, ok := tempMap[key]; ok {
-			intVal, _ := strconv.ParseInt(val, 10, 0)
-			rd.SettlementRefundFee = intVal
-		}
-
-		key = "coupon_refund_fee_" + index
-		if val, ok := tempMap[key]; ok {
-			intVal, _ := strconv.ParseInt(val, 10, 0)
-			rd.CouponRefundFee = intVal
-		}
-
-		key = "coupon_refund_count_" + index
-		if val, ok := tempMap[key]; ok {
-			intVal, _ := strconv.ParseInt(val, 10, 0)
-			rd.CouponRefundCount = int(intVal)
-		}
-
-		key = "refund_status_" + index
-		if val, ok := tempMap[key]; ok {
-			rd.RefundStatus = val
-		}
-
-		key = "refund_account_" + index
-		if val, ok := tempMap[key]; ok {
-			rd.RefundAccount = val
-		}
-
-		// 注意这里是微信的拼写错误
-		key = "refund_recv_accout_" + index
-		if val, ok := tempMap[key]; ok {
-			rd.RefundRecvAccount = val
-		}
-
-		key = "refund_success_time_" + index
-		if val, ok := tempMap[key]; ok {
-			rd.RefundSuccessTime = val
-		}
-	}
-
 	return &response, nil
 }
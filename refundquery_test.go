@@ -0,0 +1,57 @@
+package wxpay
+
+import (
+	"testing"
+)
+
+var refundQueryRespBytes = []byte(
+	`<xml>
+   <appid><![CDATA[wx2421b1c4370ec43b]]></appid>
+   <mch_id><![CDATA[10000100]]></mch_id>
+   <nonce_str><![CDATA[TeqClE3i0mvn3DrK]]></nonce_str>
+   <out_refund_no_0><![CDATA[1415701182]]></out_refund_no_0>
+   <out_trade_no><![CDATA[1415757673]]></out_trade_no>
+   <refund_count>2</refund_count>
+   <refund_fee_0>1</refund_fee_0>
+   <refund_id_0><![CDATA[2008450740201411110000174436]]></refund_id_0>
+   <refund_status_0><![CDATA[PROCESSING]]></refund_status_0>
+   <coupon_refund_count_0>1</coupon_refund_count_0>
+   <coupon_type_0_0><![CDATA[CASH]]></coupon_type_0_0>
+   <coupon_refund_id_0_0><![CDATA[10000]]></coupon_refund_id_0_0>
+   <coupon_refund_fee_0_0>1</coupon_refund_fee_0_0>
+   <out_refund_no_1><![CDATA[1415701183]]></out_refund_no_1>
+   <refund_fee_1>2</refund_fee_1>
+   <refund_recv_accout_1><![CDATA[支付用户的零钱]]></refund_recv_accout_1>
+   <refund_status_1><![CDATA[SUCCESS]]></refund_status_1>
+   <result_code><![CDATA[SUCCESS]]></result_code>
+   <return_code><![CDATA[SUCCESS]]></return_code>
+   <return_msg><![CDATA[OK]]></return_msg>
+   <sign><![CDATA[1F2841558E233C33ABA71A961D27561C]]></sign>
+   <transaction_id><![CDATA[1008450740201411110005820873]]></transaction_id>
+</xml>`,
+)
+
+func TestUnmarshalRefundQueryResponse(t *testing.T) {
+	resp := new(RefundQueryResponse)
+	if err := UnmarshalIndexed(refundQueryRespBytes, resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.RefundDetails) != 2 {
+		t.Fatalf("refund details: %d", len(resp.RefundDetails))
+	}
+	first, second := resp.RefundDetails[0], resp.RefundDetails[1]
+	if first.OutRefundNo != "1415701182" || first.RefundStatus != RefundStatusProcessing ||
+		len(first.Coupons) != 1 || first.Coupons[0].CouponRefundId != "10000" {
+		t.Errorf("first: %+v", first)
+	}
+	if second.RefundFee != 2 || second.RefundRecvAccount != "支付用户的零钱" || len(second.Coupons) != 0 {
+		t.Errorf("second: %+v", second)
+	}
+}
+
+func TestUnmarshalRefundQueryResponse_BadNumber(t *testing.T) {
+	body := []byte(`<xml><out_refund_no_0>1415701182</out_refund_no_0><refund_fee_0>0.01</refund_fee_0></xml>`)
+	if err := UnmarshalIndexed(body, new(RefundQueryResponse)); err == nil {
+		t.Error("expect error")
+	}
+}
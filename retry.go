@@ -0,0 +1,181 @@
+package wxpay
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Attempt 一次接口调用的结果，用于RetryPolicy判断是否重试
+type Attempt struct {
+	Path    string        // 接口路径，如 /pay/orderquery
+	Num     int           // 第几次调用，从1开始
+	Elapsed time.Duration // 从第一次调用开始经过的时间
+	Err     error         // 网络等错误
+	ErrCode string        // 微信返回的错误码，如 SYSTEMERROR
+}
+
+type RetryPolicy interface {
+	// Retry 返回下一次重试前需要等待的时间，ok为false时不再重试
+	Retry(attempt *Attempt) (delay time.Duration, ok bool)
+}
+
+type RetryFunc func(attempt *Attempt) (time.Duration, bool)
+
+func (f RetryFunc) Retry(attempt *Attempt) (time.Duration, bool) {
+	return f(attempt)
+}
+
+// NoRetry 不重试，企业付款等非幂等接口默认使用
+var NoRetry RetryPolicy = RetryFunc(func(*Attempt) (time.Duration, bool) {
+	return 0, false
+})
+
+// DefaultRetryPolicy 最多调用4次，等待时间从100ms开始翻倍，不超过1s
+var DefaultRetryPolicy RetryPolicy = &Backoff{
+	MaxAttempts: 4,
+	Initial:     100 * time.Millisecond,
+	Max:         1000 * time.Millisecond,
+	Multiplier:  2,
+	Jitter:      0.2,
+}
+
+// 各接口默认的重试策略，未列出的使用DefaultRetryPolicy
+var defaultRetryPolicies = map[string]RetryPolicy{
+	transferURL: NoRetry, // 重复付款的代价太大，由调用方查询后决定是否重试
+	payBankUrl:  NoRetry, // 同上，结果未知时用query_bank查询
+	microPayUrl: NoRetry, // 结果不确定时需要查询订单，见MicroPayAndWait
+	refundUrl:   NoRetry, // 退款结果未知时用refundquery查询，再用相同的out_refund_no重新申请
+}
+
+// Backoff 带随机抖动的指数退避
+type Backoff struct {
+	MaxAttempts int                         // 最多调用次数，包括第一次，0表示不限制
+	Initial     time.Duration               // 第一次重试前的等待时间
+	Max         time.Duration               // 单次等待时间的上限，0表示不限制
+	Multiplier  float64                     // 每次重试等待时间的倍数，小于1时按1处理
+	Jitter      float64                     // 随机抖动的比例，0.2表示等待时间在±20%内浮动
+	MaxElapsed  time.Duration               // 总耗时上限，超过后不再重试，0表示不限制
+	RetryIf     func(attempt *Attempt) bool // 是否需要重试，为nil时使用ShouldRetry
+}
+
+func (b *Backoff) Retry(attempt *Attempt) (time.Duration, bool) {
+	retryIf := b.RetryIf
+	if retryIf == nil {
+		retryIf = ShouldRetry
+	}
+	if !retryIf(attempt) {
+		return 0, false
+	}
+	if b.MaxAttempts > 0 && attempt.Num >= b.MaxAttempts {
+		return 0, false
+	}
+
+	delay := float64(b.Initial)
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	for i := 1; i < attempt.Num; i++ {
+		delay *= multiplier
+		if b.Max > 0 && delay > float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		delay += delay * b.Jitter * (2*rand.Float64() - 1)
+	}
+
+	if b.MaxElapsed > 0 && attempt.Elapsed+time.Duration(delay) > b.MaxElapsed {
+		return 0, false
+	}
+	return time.Duration(delay), true
+}
+
+// ShouldRetry 网络超时等临时错误，或者微信返回SYSTEMERROR、BIZERR_NEED_RETRY时重试
+func ShouldRetry(attempt *Attempt) bool {
+	if attempt.Err != nil {
+		return shouldRetry(attempt.Err)
+	}
+	switch attempt.ErrCode {
+	case errCodeSystemError, errCodeBizerrNeedRetry:
+		return true
+	default:
+		return false
+	}
+}
+
+// WithRetryPolicy 修改没有单独指定策略的接口的重试策略，
+// 企业付款等默认不重试的接口需要用WithEndpointRetryPolicy修改
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		c.retryPolicy = policy
+		return nil
+	}
+}
+
+// WithEndpointRetryPolicy 修改单个接口的重试策略，path如 /pay/orderquery
+func WithEndpointRetryPolicy(path string, policy RetryPolicy) Option {
+	return func(c *Client) error {
+		if c.endpointRetryPolicies == nil {
+			c.endpointRetryPolicies = make(map[string]RetryPolicy)
+		}
+		c.endpointRetryPolicies[path] = policy
+		return nil
+	}
+}
+
+// WithAttemptHook 每次调用接口后都会执行hook，可用于记录日志和监控
+func WithAttemptHook(hook func(attempt *Attempt)) Option {
+	return func(c *Client) error {
+		c.attemptHook = hook
+		return nil
+	}
+}
+
+func (c *Client) retryPolicyOf(path string) RetryPolicy {
+	if policy, ok := c.endpointRetryPolicies[path]; ok {
+		return policy
+	}
+	if policy, ok := defaultRetryPolicies[path]; ok {
+		return policy
+	}
+	if c.retryPolicy != nil {
+		return c.retryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
+// 按path对应的RetryPolicy调用fn，fn返回微信的错误码和其他错误
+func (c *Client) retry(ctx context.Context, path string, fn func() (errCode string, err error)) error {
+	policy := c.retryPolicyOf(path)
+	start := time.Now()
+	for num := 1; ; num++ {
+		errCode, err := fn()
+		attempt := &Attempt{
+			Path:    path,
+			Num:     num,
+			Elapsed: time.Since(start),
+			Err:     err,
+			ErrCode: errCode,
+		}
+		if c.attemptHook != nil {
+			c.attemptHook(attempt)
+		}
+		delay, ok := policy.Retry(attempt)
+		if !ok {
+			return err
+		}
+		if err != nil {
+			notifyAsync(path+" err: ", err)
+		} else {
+			notifyAsync(path+" err: ", errCode)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
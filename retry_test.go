@@ -0,0 +1,88 @@
+package wxpay
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoff_Retry(t *testing.T) {
+	b := &Backoff{
+		MaxAttempts: 5,
+		Initial:     100 * time.Millisecond,
+		Max:         300 * time.Millisecond,
+		Multiplier:  2,
+		MaxElapsed:  time.Second,
+	}
+	expects := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, expect := range expects {
+		delay, ok := b.Retry(&Attempt{Num: i + 1, ErrCode: errCodeSystemError})
+		if !ok || delay != expect {
+			t.Errorf("attempt %d: expect %v, got %v %v", i+1, expect, delay, ok)
+		}
+	}
+	if _, ok := b.Retry(&Attempt{Num: 5, ErrCode: errCodeSystemError}); ok {
+		t.Error("should stop after MaxAttempts")
+	}
+	if _, ok := b.Retry(&Attempt{Num: 1, ErrCode: errCodeOrderPaid}); ok {
+		t.Error("should not retry ORDERPAID")
+	}
+	if _, ok := b.Retry(&Attempt{Num: 2, Elapsed: 900 * time.Millisecond, ErrCode: errCodeSystemError}); ok {
+		t.Error("should stop after MaxElapsed")
+	}
+}
+
+func TestBackoff_Jitter(t *testing.T) {
+	b := &Backoff{Initial: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		delay, ok := b.Retry(&Attempt{Num: 1, ErrCode: errCodeSystemError})
+		if !ok || delay < 50*time.Millisecond || delay > 150*time.Millisecond {
+			t.Fatalf("delay out of range: %v", delay)
+		}
+	}
+}
+
+func TestClient_RetryPolicyPerEndpoint(t *testing.T) {
+	calls := make(map[string]int)
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		calls[path]++
+		return Map{
+			"return_code": "SUCCESS",
+			"result_code": "FAIL",
+			"err_code":    "SYSTEMERROR",
+		}
+	})
+	defer server.Close()
+
+	var attempts int
+	noWait := &Backoff{MaxAttempts: 3}
+	c, err := NewClient(testApiKey, "1900000109",
+		WithBaseUrl(server.URL),
+		WithTransport(http.DefaultTransport),
+		withTestCert(t),
+		WithRetryPolicy(noWait),
+		WithAttemptHook(func(*Attempt) { attempts++ }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.OrderQuery(&OrderQueryRequest{OutTradeNo: "1415757673"})
+	if calls[orderQueryUrl] != 3 {
+		t.Errorf("orderquery called %d times", calls[orderQueryUrl])
+	}
+
+	c.Transfer(newTransferRequest())
+	if calls[transferURL] != 1 {
+		t.Errorf("transfer called %d times", calls[transferURL])
+	}
+
+	c.Refund(&RefundRequest{OutTradeNo: "1415757673", OutRefundNo: "1415757673001", TotalFee: 1, RefundFee: 1})
+	if calls[refundUrl] != 1 {
+		t.Errorf("refund called %d times", calls[refundUrl])
+	}
+
+	if attempts != 5 {
+		t.Errorf("hook called %d times", attempts)
+	}
+}
@@ -1,15 +1,20 @@
 package wxpay
 
-import "encoding/xml"
+import (
+	"context"
+	"encoding/xml"
+)
 
 const (
-	reverseUrl = "https://api.mch.weixin.qq.com/secapi/pay/reverse"
+	reverseUrl = "/secapi/pay/reverse"
 )
 
 type ReverseRequest struct {
 	XMLName       xml.Name `xml:"xml"`
 	AppId         string   `xml:"appid,omitempty"`
 	MchId         string   `xml:"mch_id,omitempty"`
+	SubAppId      string   `xml:"sub_appid,omitempty"`
+	SubMchId      string   `xml:"sub_mch_id,omitempty"`
 	TransactionId string   `xml:"transaction_id,omitempty"`
 	OutTradeNo    string   `xml:"out_trade_no,omitempty"`
 	NonceStr      string   `xml:"nonce_str,omitempty"`
@@ -21,6 +26,8 @@ type ReverseResponse struct {
 	Meta
 	AppId    string `xml:"appid"`
 	MchId    string `xml:"mch_id"`
+	SubAppId string `xml:"sub_appid"`
+	SubMchId string `xml:"sub_mch_id"`
 	NonceStr string `xml:"nonce_str"`
 	Sign     string `xml:"sign"`
 	Recall   string `xml:"recall"`
@@ -28,11 +35,19 @@ type ReverseResponse struct {
 
 // 仅用于刷卡支付
 func (c *Client) Reverse(request *ReverseRequest) (*ReverseResponse, error) {
+	return c.ReverseContext(context.Background(), request)
+}
+
+func (c *Client) ReverseContext(ctx context.Context, request *ReverseRequest) (*ReverseResponse, error) {
 	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
 	request.NonceStr = nonceStr()
-	request.Sign = signStruct(request, c.apiKey)
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response ReverseResponse
-	_, err := c.request(reverseUrl, request, &response)
+	_, err = c.request(ctx, reverseUrl, request, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,76 @@
+package wxpay
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"sync"
+)
+
+// https://pay.weixin.qq.com/wiki/doc/api/micropay.php?chapter=23_1&index=2
+
+const (
+	sandboxPrefix = "/sandboxnew"
+	getSignKeyUrl = "/pay/getsignkey"
+)
+
+type sandbox struct {
+	mu      sync.Mutex
+	signKey string
+}
+
+type GetSignKeyRequest struct {
+	XMLName  xml.Name `xml:"xml"`
+	MchId    string   `xml:"mch_id,omitempty"`
+	NonceStr string   `xml:"nonce_str,omitempty"`
+	Sign     string   `xml:"sign,omitempty"`
+}
+
+type GetSignKeyResponse struct {
+	ReturnCode     string `xml:"return_code"`
+	ReturnMsg      string `xml:"return_msg"`
+	MchId          string `xml:"mch_id"`
+	SandboxSignKey string `xml:"sandbox_signkey"`
+}
+
+// WithSandbox 使用仿真测试系统，所有接口加上/sandboxnew前缀，
+// 签名使用从getsignkey接口获取的沙箱密钥
+func WithSandbox() Option {
+	return func(c *Client) error {
+		c.sandbox = new(sandbox)
+		return nil
+	}
+}
+
+// 签名使用的密钥，沙箱模式下首次调用时获取并缓存沙箱密钥
+func (c *Client) signKey(ctx context.Context) (string, error) {
+	if c.sandbox == nil {
+		return c.apiKey, nil
+	}
+	c.sandbox.mu.Lock()
+	defer c.sandbox.mu.Unlock()
+	if c.sandbox.signKey != "" {
+		return c.sandbox.signKey, nil
+	}
+
+	request := &GetSignKeyRequest{
+		MchId:    c.mchId,
+		NonceStr: nonceStr(),
+	}
+	// 获取沙箱密钥本身使用正式的api密钥签名
+	request.Sign = signStruct(request, c.apiKey)
+	body, err := c.post(ctx, getSignKeyUrl, request)
+	if err != nil {
+		return "", err
+	}
+	var response GetSignKeyResponse
+	if err := xml.Unmarshal(body, &response); err != nil {
+		globalLogger.printf("unmarshal body err: %s, body: %s", err.Error(), string(body))
+		return "", err
+	}
+	if response.ReturnCode != success || response.SandboxSignKey == "" {
+		return "", errors.New("getsignkey: " + response.ReturnMsg)
+	}
+	c.sandbox.signKey = response.SandboxSignKey
+	return c.sandbox.signKey, nil
+}
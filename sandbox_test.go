@@ -0,0 +1,82 @@
+package wxpay
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+)
+
+func TestWithSandbox(t *testing.T) {
+	const sandboxKey = "5a1f9b1b0c0e4c1d8d1e2f3a4b5c6d7e"
+	var signKeyCalls int
+	server := newTestServer(t, sandboxKey, func(path string, req Map) Map {
+		switch path {
+		case "/sandboxnew/pay/getsignkey":
+			signKeyCalls++
+			if req["sign"] != sign(req, testApiKey, "") {
+				t.Error("getsignkey should be signed with api key")
+			}
+			return Map{
+				"return_code":     "SUCCESS",
+				"mch_id":          req["mch_id"],
+				"sandbox_signkey": sandboxKey,
+			}
+		case "/sandboxnew/pay/orderquery":
+			if req["sign"] != sign(req, sandboxKey, "") {
+				t.Error("orderquery should be signed with sandbox key")
+			}
+			return Map{
+				"return_code": "SUCCESS",
+				"result_code": "SUCCESS",
+				"trade_state": "SUCCESS",
+			}
+		default:
+			t.Errorf("unexpected path: %s", path)
+			return Map{"return_code": "FAIL"}
+		}
+	})
+	defer server.Close()
+
+	c, err := NewClient(testApiKey, "1900000109", WithBaseUrl(server.URL), WithSandbox())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.OrderQuery(&OrderQueryRequest{OutTradeNo: "1415757673"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if signKeyCalls != 1 {
+		t.Errorf("getsignkey called %d times", signKeyCalls)
+	}
+
+	// 沙箱环境的支付通知和JSAPI的paySign同样使用沙箱密钥
+	notify := Map{
+		"return_code":    "SUCCESS",
+		"result_code":    "SUCCESS",
+		"mch_id":         "1900000109",
+		"nonce_str":      "5d2b6c2a8db53831f7eda20af46e531c",
+		"out_trade_no":   "1409811653",
+		"total_fee":      "101",
+		"transaction_id": "1004400740201409030005092168",
+	}
+	notify["sign"] = sign(notify, sandboxKey, "")
+	var body bytes.Buffer
+	if err := xml.NewEncoder(&body).EncodeElement(notify, xml.StartElement{Name: xml.Name{Local: "xml"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.PaidVerify(body.Bytes()); err != nil {
+		t.Errorf("sandbox notify: %v", err)
+	}
+
+	var req BrandWCPayRequest
+	if err := json.Unmarshal([]byte(c.GetBrandWCPayRequest(&UnifiedOrderResponse{PrepayId: "wx201410272009395522657a690389285100"})), &req); err != nil {
+		t.Fatal(err)
+	}
+	paySign := req.PaySign
+	req.PaySign = ""
+	if paySign != signStruct(&req, sandboxKey) {
+		t.Errorf("paySign: %s", paySign)
+	}
+}
@@ -1,13 +1,14 @@
 package wxpay
 
 import (
+	"context"
 	"encoding/xml"
 )
 
 // https://pay.weixin.qq.com/wiki/doc/api/native.php?chapter=9_9&index=10
 
 const (
-	shortUrl = "https://api.mch.weixin.qq.com/tools/shorturl"
+	shortUrl = "/tools/shorturl"
 )
 
 type ShortUrlRequest struct {
@@ -30,11 +31,18 @@ type ShortUrlResponse struct {
 }
 
 func (c *Client) ShortUrl(request *ShortUrlRequest) (*ShortUrlResponse, error) {
+	return c.ShortUrlContext(context.Background(), request)
+}
+
+func (c *Client) ShortUrlContext(ctx context.Context, request *ShortUrlRequest) (*ShortUrlResponse, error) {
 	request.MchId = c.mchId
 	request.NonceStr = nonceStr()
-	request.Sign = signStruct(request, c.apiKey)
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response ShortUrlResponse
-	_, err := c.request(shortUrl, request, &response)
+	_, err = c.request(ctx, shortUrl, request, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -1,16 +1,26 @@
 package wxpay
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/xml"
 	"fmt"
+	"hash"
 	"reflect"
 	"sort"
 	"strings"
 )
 
-func checkSign(stream []byte, key string) (err error) {
+const (
+	SignTypeMD5        = "MD5"
+	SignTypeHmacSha256 = "HMAC-SHA256"
+)
+
+// 校验签名，优先使用报文中声明的sign_type，未声明时使用signType
+func checkSign(stream []byte, key string, signType string) (err error) {
 	defer func() {
 		if err != nil {
 			notifyAsync(string(stream), err)
@@ -22,21 +32,32 @@ func checkSign(stream []byte, key string) (err error) {
 		return
 	}
 
-	// fengzie: 企业付款到零钱成功时没有sign值，但有专有的mch_appid
-	if reqMap["mch_appid"] != "" {
-		err = nil
+	if reqMap["sign_type"] != "" {
+		signType = reqMap["sign_type"]
+	}
+	if !validSignType(signType) {
+		err = signTypeNotSupportErr
 		return
 	}
 
-	md5Sign := sign(reqMap, key)
-	if reqMap["sign"] != md5Sign {
+	if reqMap["sign"] != sign(reqMap, key, signType) {
 		err = signNotMatchErr
 		return
 	}
 	return
 }
 
-func sign(req map[string]string, key string) string {
+func validSignType(signType string) bool {
+	switch signType {
+	case "", SignTypeMD5, SignTypeHmacSha256:
+		return true
+	default:
+		return false
+	}
+}
+
+// signType为空时使用MD5
+func sign(req map[string]string, key string, signType string) string {
 	// #1.对参数按照key=value的格式，并按照参数名ASCII字典序排序生成字符串：
 	sortedKeys := make([]string, 0)
 	for k, _ := range req {
@@ -60,9 +81,15 @@ func sign(req map[string]string, key string) string {
 	signStrings = signStrings + "key=" + key
 
 	// #3.生成sign并转成大写：
-	hash := md5.New()
-	hash.Write([]byte(signStrings))
-	upperSign := strings.ToUpper(hex.EncodeToString(hash.Sum(nil)))
+	var h hash.Hash
+	switch signType {
+	case SignTypeHmacSha256:
+		h = hmac.New(sha256.New, []byte(key))
+	default:
+		h = md5.New()
+	}
+	h.Write([]byte(signStrings))
+	upperSign := strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
 
 	// #4.校验结果：
 	globalLogger.printf("待签名字符串: %s", signStrings)
@@ -70,9 +97,30 @@ func sign(req map[string]string, key string) string {
 	return upperSign
 }
 
+// 签名类型取结构体中的sign_type字段，JSAPI调起支付的参数则是signType
 func signStruct(v interface{}, key string) string {
 	req := convert(v)
-	return sign(req, key)
+	signType := req["sign_type"]
+	if signType == "" {
+		signType = req["signType"]
+	}
+	return sign(req, key, signType)
+}
+
+// 结构体中声明的签名类型
+func signTypeOf(v interface{}) string {
+	return convert(v)["sign_type"]
+}
+
+func (c *Client) signStruct(ctx context.Context, v interface{}) (string, error) {
+	if !validSignType(signTypeOf(v)) {
+		return "", signTypeNotSupportErr
+	}
+	key, err := c.signKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	return signStruct(v, key), nil
 }
 
 func convert(str interface{}) map[string]string {
@@ -0,0 +1,62 @@
+package wxpay
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// https://pay.weixin.qq.com/wiki/doc/api/jsapi.php?chapter=4_3 中的示例
+var signExample = map[string]string{
+	"appid":       "wxd930ea5d5a258f4f",
+	"mch_id":      "10000100",
+	"device_info": "1000",
+	"body":        "test",
+	"nonce_str":   "ibuaiVcKdpRxkhJA",
+}
+
+const signExampleKey = "192006250b4c09247ec02edce69f6a2d"
+
+func TestSign_MD5(t *testing.T) {
+	if s := sign(signExample, signExampleKey, SignTypeMD5); s != "9A0A8659F005D6984697E2CA0A9CF3B7" {
+		t.Error(s)
+	}
+}
+
+func TestSign_HmacSha256(t *testing.T) {
+	if s := sign(signExample, signExampleKey, SignTypeHmacSha256); s != "6A9AE1657590FD6257D693A078E1C3E4BB6BA4DC30B23E0EE2496E54170DACD6" {
+		t.Error(s)
+	}
+}
+
+func TestCheckSign_DeclaredSignType(t *testing.T) {
+	body := []byte(`<xml>
+  <return_code><![CDATA[SUCCESS]]></return_code>
+  <sign_type><![CDATA[HMAC-SHA256]]></sign_type>
+  <nonce_str><![CDATA[5d2b6c2a8db53831f7eda20af46e531c]]></nonce_str>
+  <sign><![CDATA[` + sign(map[string]string{
+		"return_code": "SUCCESS",
+		"sign_type":   SignTypeHmacSha256,
+		"nonce_str":   "5d2b6c2a8db53831f7eda20af46e531c",
+	}, signExampleKey, SignTypeHmacSha256) + `]]></sign>
+</xml>`)
+	if err := checkSign(body, signExampleKey, SignTypeMD5); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_GetBrandWCPayRequestWithSignType(t *testing.T) {
+	c := New(signExampleKey, "10000100")
+	var req BrandWCPayRequest
+	s := c.GetBrandWCPayRequestWithSignType(&UnifiedOrderResponse{
+		AppId:    "wxd930ea5d5a258f4f",
+		PrepayId: "wx201410272009395522657a690389285100",
+	}, SignTypeHmacSha256)
+	if err := json.Unmarshal([]byte(s), &req); err != nil {
+		t.Fatal(err)
+	}
+	paySign := req.PaySign
+	req.PaySign = ""
+	if req.SignType != SignTypeHmacSha256 || paySign != signStruct(&req, signExampleKey) {
+		t.Error(s)
+	}
+}
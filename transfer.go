@@ -1,28 +1,47 @@
 package wxpay
 
-import "encoding/xml"
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
 
 // https://pay.weixin.qq.com/wiki/doc/api/tools/mch_pay.php?chapter=14_2
 
 const (
-	transferURL = "https://api.mch.weixin.qq.com/mmpaymkttransfers/promotion/transfers"
+	transferURL = "/mmpaymkttransfers/promotion/transfers"
+)
+
+// CheckName 校验用户姓名选项
+type CheckName string
+
+const (
+	CheckNameNoCheck    CheckName = "NO_CHECK"    // 不校验真实姓名
+	CheckNameForceCheck CheckName = "FORCE_CHECK" // 强校验真实姓名，需要填写re_user_name
 )
 
+// 企业付款到零钱的单笔最低金额，单位分
+const transferMinAmount = 30
+
 // TransferRequest ...
 type TransferRequest struct {
-	XMLName        xml.Name `xml:"xml"`
-	AppID          string   `xml:"mch_appid,omitempty"`
-	MchID          string   `xml:"mchid,omitempty"`
-	DeviceInfo     string   `xml:"device_info,omitempty"`
-	NonceStr       string   `xml:"nonce_str,omitempty"`
-	Sign           string   `xml:"sign,omitempty"`
-	PartnerTradeNo string   `xml:"partner_trade_no,omitempty"` // 商户订单号
-	OpenID         string   `xml:"openid,omitempty"`
-	CheckName      string   `xml:"check_name,omitempty"` // NO_CHECK：不校验真实姓名, FORCE_CHECK：强校验真实姓名
-	ReUserName     string   `xml:"re_user_name,omitempty"`
-	Amount         string   `xml:"amount,omitempty"` // 单位分
-	Desc           string   `xml:"desc,omitempty"`   // 企业付款备注
-	SpBillCreateIP string   `xml:"spbill_create_ip,omitempty"`
+	XMLName        xml.Name  `xml:"xml"`
+	AppID          string    `xml:"mch_appid,omitempty"`
+	MchID          string    `xml:"mchid,omitempty"`
+	DeviceInfo     string    `xml:"device_info,omitempty"`
+	NonceStr       string    `xml:"nonce_str,omitempty"`
+	Sign           string    `xml:"sign,omitempty"`
+	PartnerTradeNo string    `xml:"partner_trade_no,omitempty"` // 商户订单号
+	OpenID         string    `xml:"openid,omitempty"`
+	CheckName      CheckName `xml:"check_name,omitempty"`
+	ReUserName     string    `xml:"re_user_name,omitempty"`
+	Amount         int64     `xml:"amount,omitempty"`           // 单位分，最低30分
+	Desc           string    `xml:"desc,omitempty"`             // 企业付款备注
+	SpBillCreateIP string    `xml:"spbill_create_ip,omitempty"` // 调用接口的机器IP
 }
 
 // TransferResponse ...
@@ -39,13 +58,228 @@ type TransferResponse struct {
 
 // Transfer 企业付款到零钱
 func (c *Client) Transfer(request *TransferRequest) (*TransferResponse, error) {
+	return c.TransferContext(context.Background(), request)
+}
+
+func (c *Client) TransferContext(ctx context.Context, request *TransferRequest) (*TransferResponse, error) {
 	request.MchID = c.mchId
 	request.NonceStr = nonceStr()
-	request.Sign = signStruct(request, c.apiKey)
+
+	if len(request.AppID) == 0 {
+		return nil, errors.New("mch_appid is zero")
+	}
+
+	if len(request.PartnerTradeNo) == 0 {
+		return nil, errors.New("partner_trade_no is zero")
+	}
+
+	if len(request.OpenID) == 0 {
+		return nil, errors.New("openid is zero")
+	}
+
+	switch request.CheckName {
+	case CheckNameNoCheck:
+	case CheckNameForceCheck:
+		if len(request.ReUserName) == 0 {
+			return nil, errors.New("re_user_name is zero")
+		}
+	default:
+		return nil, errors.New("wrong check_name")
+	}
+
+	if request.Amount < transferMinAmount {
+		return nil, errors.New("wrong amount")
+	}
+
+	if len(request.Desc) == 0 {
+		return nil, errors.New("desc is zero")
+	}
+
+	if len(request.SpBillCreateIP) == 0 {
+		return nil, errors.New("spbill_create_ip is zero")
+	}
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response TransferResponse
-	_, err := c.request(transferURL, request, &response)
+	_, err = c.request(ctx, transferURL, request, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+const (
+	transferInfoURL = "/mmpaymkttransfers/gettransferinfo"
+)
+
+const (
+	TransferStatusSuccess    = "SUCCESS"    // 转账成功
+	TransferStatusFailed     = "FAILED"     // 转账失败
+	TransferStatusProcessing = "PROCESSING" // 处理中
+)
+
+// TransferInfoRequest 查询企业付款
+type TransferInfoRequest struct {
+	XMLName        xml.Name `xml:"xml"`
+	AppID          string   `xml:"appid,omitempty"`
+	MchID          string   `xml:"mch_id,omitempty"`
+	NonceStr       string   `xml:"nonce_str,omitempty"`
+	Sign           string   `xml:"sign,omitempty"`
+	PartnerTradeNo string   `xml:"partner_trade_no,omitempty"` // 商户订单号
+}
+
+// TransferInfoResponse 付款单不存在时ErrCode为NOT_FOUND
+type TransferInfoResponse struct {
+	Meta
+	AppID          string `xml:"appid"`
+	MchID          string `xml:"mch_id"`
+	PartnerTradeNo string `xml:"partner_trade_no"` // 商户订单号
+	DetailID       string `xml:"detail_id"`        // 微信付款单号
+	Status         string `xml:"status"`           // SUCCESS/FAILED/PROCESSING
+	Reason         string `xml:"reason"`           // 失败原因
+	OpenID         string `xml:"openid"`
+	TransferName   string `xml:"transfer_name"`  // 收款用户姓名
+	PaymentAmount  int64  `xml:"payment_amount"` // 单位分
+	TransferTime   string `xml:"transfer_time"`  // 发起转账的时间
+	PaymentTime    string `xml:"payment_time"`   // 转账成功的时间
+	Desc           string `xml:"desc"`           // 企业付款备注
+}
+
+// GetTransferInfo 查询企业付款到零钱的结果，需要api证书
+func (c *Client) GetTransferInfo(request *TransferInfoRequest) (*TransferInfoResponse, error) {
+	return c.GetTransferInfoContext(context.Background(), request)
+}
+
+func (c *Client) GetTransferInfoContext(ctx context.Context, request *TransferInfoRequest) (*TransferInfoResponse, error) {
+	request.MchID = c.mchId
+	request.NonceStr = nonceStr()
+
+	if len(request.PartnerTradeNo) == 0 {
+		return nil, errors.New("partner_trade_no is zero")
+	}
+
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
+	var response TransferInfoResponse
+	_, err = c.request(ctx, transferInfoURL, request, &response)
 	if err != nil {
 		return nil, err
 	}
 	return &response, nil
 }
+
+type TransferConfirmOptions struct {
+	Interval  time.Duration // 查询付款结果的间隔，默认3秒
+	MaxQuery  int           // 每次结果未知时查询的最多次数，默认5次
+	MaxSubmit int           // 提交付款的最多次数，默认3次
+}
+
+// TransferResult 企业付款的最终结果
+type TransferResult struct {
+	Paid     bool
+	Transfer *TransferResponse     // 最后一次提交付款的返回，网络错误时为nil
+	Info     *TransferInfoResponse // 最后一次查询到的付款单，没有查询时为nil
+}
+
+// TransferAndConfirm 企业付款到零钱，结果未知(SYSTEMERROR、SEND_FAILED或网络错误)时
+// 先用同一个partner_trade_no查询付款单，确认付款单不存在后才用原单号重新提交，避免重复付款。
+// 付款成功返回的error为nil，确认失败时返回的error满足IsTransferFailed，
+// 多次查询仍无法确认时满足IsTransferUnknown，此时以及ctx被取消时都不能换单号重新付款
+func (c *Client) TransferAndConfirm(ctx context.Context, request *TransferRequest, opts *TransferConfirmOptions) (*TransferResult, error) {
+	var o TransferConfirmOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Interval <= 0 {
+		o.Interval = 3 * time.Second
+	}
+	if o.MaxQuery <= 0 {
+		o.MaxQuery = 5
+	}
+	if o.MaxSubmit <= 0 {
+		o.MaxSubmit = 3
+	}
+
+	if len(request.PartnerTradeNo) == 0 {
+		return nil, errors.New("partner_trade_no is zero")
+	}
+
+	var result TransferResult
+	for submit := 1; ; submit++ {
+		var err error
+		result.Transfer, err = c.TransferContext(ctx, request)
+		if err == nil {
+			if result.Transfer.ResultCodeSuccess() {
+				result.Paid = true
+				return &result, nil
+			}
+			err = result.Transfer.Err()
+		}
+		if !transferUnknown(err) {
+			// 明确的失败，如余额不足、实名校验失败
+			return &result, err
+		}
+		globalLogger.printf("transfer %s unknown: %v", request.PartnerTradeNo, err)
+
+		status, err := c.confirmTransfer(ctx, request, &result, o)
+		switch {
+		case err != nil:
+			return &result, err
+		case status == TransferStatusSuccess:
+			result.Paid = true
+			return &result, nil
+		case status == TransferStatusFailed:
+			return &result, transferFailedErr
+		case status == errCodeNotFound && submit < o.MaxSubmit:
+			continue
+		default:
+			return &result, transferUnknownErr
+		}
+	}
+}
+
+// 查询付款单直到得到确定的结果，返回付款单状态，付款单不存在时返回NOT_FOUND，
+// 多次查询仍未确定时返回空字符串
+func (c *Client) confirmTransfer(ctx context.Context, request *TransferRequest, result *TransferResult, o TransferConfirmOptions) (string, error) {
+	for i := 0; i < o.MaxQuery; i++ {
+		if err := sleep(ctx, o.Interval); err != nil {
+			return "", err
+		}
+		info, err := c.GetTransferInfoContext(ctx, &TransferInfoRequest{
+			AppID:          request.AppID,
+			PartnerTradeNo: request.PartnerTradeNo,
+		})
+		if err == nil {
+			result.Info = info
+			err = info.Err()
+		}
+		if apiErr, ok := err.(*APIError); ok && apiErr.ErrCode == errCodeNotFound {
+			return errCodeNotFound, nil
+		}
+		if err != nil {
+			globalLogger.printf("gettransferinfo err: %v", err)
+			continue
+		}
+		switch info.Status {
+		case TransferStatusSuccess, TransferStatusFailed:
+			return info.Status, nil
+		}
+	}
+	return "", nil
+}
+
+// 付款请求可能已经被微信处理，需要查询才能确定结果
+func transferUnknown(err error) bool {
+	switch err := err.(type) {
+	case *APIError:
+		return err.ErrCode == errCodeSystemError || err.ErrCode == errCodeSendFailed
+	case *url.Error, net.Error, *xml.SyntaxError:
+		return true
+	default:
+		return err == io.ErrUnexpectedEOF
+	}
+}
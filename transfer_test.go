@@ -0,0 +1,157 @@
+package wxpay
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTransferClient(t *testing.T, transfer func(req Map) Map, info func(req Map) Map) (*Client, map[string]int, func()) {
+	calls := make(map[string]int)
+	server := newTestServer(t, testApiKey, func(path string, req Map) Map {
+		calls[path]++
+		if req["partner_trade_no"] != "10000098201411111234567890" {
+			t.Errorf("partner_trade_no: %s", req["partner_trade_no"])
+		}
+		switch path {
+		case transferURL:
+			return transfer(req)
+		case transferInfoURL:
+			return info(req)
+		default:
+			t.Errorf("unexpected path: %s", path)
+			return Map{}
+		}
+	})
+	c, err := NewClient(testApiKey, "10000098", WithBaseUrl(server.URL), WithTransport(http.DefaultTransport), withTestCert(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, calls, server.Close
+}
+
+func newTransferRequest() *TransferRequest {
+	return &TransferRequest{
+		AppID:          "wx8888888888888888",
+		PartnerTradeNo: "10000098201411111234567890",
+		OpenID:         "oxTWIuGaIt6gTKsQRLau2M0yL16E",
+		CheckName:      CheckNameNoCheck,
+		Amount:         100,
+		Desc:           "理赔",
+		SpBillCreateIP: "192.168.0.1",
+	}
+}
+
+var transferOptions = &TransferConfirmOptions{Interval: time.Millisecond}
+
+func TestClient_TransferAndConfirm_Resubmit(t *testing.T) {
+	c, calls, closeServer := newTransferClient(t, func(req Map) Map {
+		if req["nonce_str"] == "" {
+			t.Error("nonce_str is empty")
+		}
+		return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "SYSTEMERROR"}
+	}, func(req Map) Map {
+		return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "NOT_FOUND"}
+	})
+	defer closeServer()
+
+	result, err := c.TransferAndConfirm(context.Background(), newTransferRequest(), transferOptions)
+	if !IsTransferUnknown(err) || result.Paid {
+		t.Errorf("err: %v, result: %+v", err, result)
+	}
+	if calls[transferURL] != 3 || calls[transferInfoURL] != 3 {
+		t.Errorf("calls: %v", calls)
+	}
+}
+
+func TestClient_TransferAndConfirm_Paid(t *testing.T) {
+	queries := 0
+	c, calls, closeServer := newTransferClient(t, func(req Map) Map {
+		return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "SEND_FAILED"}
+	}, func(req Map) Map {
+		queries++
+		if queries == 1 {
+			return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "status": TransferStatusProcessing}
+		}
+		return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "status": TransferStatusSuccess, "detail_id": "1000000000201503283103439304"}
+	})
+	defer closeServer()
+
+	result, err := c.TransferAndConfirm(context.Background(), newTransferRequest(), transferOptions)
+	if err != nil || !result.Paid || result.Info.DetailID != "1000000000201503283103439304" {
+		t.Errorf("err: %v, result: %+v", err, result)
+	}
+	if calls[transferURL] != 1 || calls[transferInfoURL] != 2 {
+		t.Errorf("calls: %v", calls)
+	}
+}
+
+func TestClient_TransferAndConfirm_Failed(t *testing.T) {
+	c, _, closeServer := newTransferClient(t, func(req Map) Map {
+		return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "SYSTEMERROR"}
+	}, func(req Map) Map {
+		return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "status": TransferStatusFailed, "reason": "用户账户异常"}
+	})
+	defer closeServer()
+
+	result, err := c.TransferAndConfirm(context.Background(), newTransferRequest(), transferOptions)
+	if !IsTransferFailed(err) || result.Info.Reason != "用户账户异常" {
+		t.Errorf("err: %v, result: %+v", err, result)
+	}
+}
+
+func TestClient_TransferAndConfirm_NotEnough(t *testing.T) {
+	c, calls, closeServer := newTransferClient(t, func(req Map) Map {
+		return Map{"return_code": "SUCCESS", "result_code": "FAIL", "err_code": "NOTENOUGH"}
+	}, func(req Map) Map {
+		return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "status": TransferStatusSuccess}
+	})
+	defer closeServer()
+
+	_, err := c.TransferAndConfirm(context.Background(), newTransferRequest(), transferOptions)
+	if !errors.Is(err, ErrNotEnough) || calls[transferInfoURL] != 0 {
+		t.Errorf("err: %v, calls: %v", err, calls)
+	}
+}
+
+func TestClient_TransferValidation(t *testing.T) {
+	c, calls, closeServer := newTransferClient(t, func(req Map) Map {
+		return Map{"return_code": "SUCCESS", "result_code": "SUCCESS", "mch_appid": req["mch_appid"]}
+	}, nil)
+	defer closeServer()
+
+	tests := []struct {
+		modify func(r *TransferRequest)
+		err    string
+	}{
+		{func(r *TransferRequest) { r.AppID = "" }, "mch_appid is zero"},
+		{func(r *TransferRequest) { r.PartnerTradeNo = "" }, "partner_trade_no is zero"},
+		{func(r *TransferRequest) { r.OpenID = "" }, "openid is zero"},
+		{func(r *TransferRequest) { r.CheckName = "" }, "wrong check_name"},
+		{func(r *TransferRequest) { r.CheckName = CheckNameForceCheck }, "re_user_name is zero"},
+		{func(r *TransferRequest) { r.Amount = 29 }, "wrong amount"},
+		{func(r *TransferRequest) { r.SpBillCreateIP = "" }, "spbill_create_ip is zero"},
+		{func(r *TransferRequest) { r.Desc = "" }, "desc is zero"},
+	}
+	for _, test := range tests {
+		request := newTransferRequest()
+		test.modify(request)
+		if _, err := c.Transfer(request); err == nil || err.Error() != test.err {
+			t.Errorf("err: %v, want %s", err, test.err)
+		}
+	}
+	if calls[transferURL] != 0 {
+		t.Errorf("invalid transfers were sent: %v", calls)
+	}
+
+	request := newTransferRequest()
+	request.CheckName = CheckNameForceCheck
+	request.ReUserName = "张三"
+	request.Amount = transferMinAmount
+	resp, err := c.Transfer(request)
+	if err != nil || !resp.ResultCodeSuccess() {
+		t.Errorf("err: %v, response: %+v", err, resp)
+	}
+}
@@ -1,6 +1,7 @@
 package wxpay
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -9,13 +10,15 @@ import (
 )
 
 const (
-	unifiedOrderUrl = "https://api.mch.weixin.qq.com/pay/unifiedorder"
+	unifiedOrderUrl = "/pay/unifiedorder"
 )
 
 type UnifiedOrderRequest struct {
 	XMLName        xml.Name `xml:"xml"`
 	AppId          string   `xml:"appid,omitempty"`
 	MchId          string   `xml:"mch_id,omitempty"`
+	SubAppId       string   `xml:"sub_appid,omitempty"`
+	SubMchId       string   `xml:"sub_mch_id,omitempty"`
 	DeviceInfo     string   `xml:"device_info,omitempty"`
 	NonceStr       string   `xml:"nonce_str,omitempty"`
 	Sign           string   `xml:"sign,omitempty"`
@@ -35,6 +38,7 @@ type UnifiedOrderRequest struct {
 	ProductId      string   `xml:"product_id,omitempty"`
 	LimitPay       string   `xml:"limit_pay,omitempty"`
 	OpenId         string   `xml:"openid,omitempty"`
+	SubOpenId      string   `xml:"sub_openid,omitempty"` // 服务商模式下用户在子商户appid下的唯一标识
 	SceneInfo      string   `xml:"scene_info,omitempty"`
 }
 
@@ -49,6 +53,8 @@ type UnifiedOrderResponse struct {
 	Meta
 	AppId      string `xml:"appid"`
 	MchId      string `xml:"mch_id"`
+	SubAppId   string `xml:"sub_appid"`
+	SubMchId   string `xml:"sub_mch_id"`
 	DeviceInfo string `xml:"device_info"`
 	NonceStr   string `xml:"nonce_str"`
 	Sign       string `xml:"sign"`
@@ -59,13 +65,17 @@ type UnifiedOrderResponse struct {
 }
 
 // 必填参数 body，out_trade_no，total_fee，spbill_create_ip，notify_url，trade_type
-// 如果是公众号支付，必填openid
+// 如果是公众号支付，必填openid，服务商模式下可以用sub_openid代替
 // 如果是h5支付，必填scene_info
 func (c *Client) UnifiedOrder(request *UnifiedOrderRequest) (*UnifiedOrderResponse, error) {
+	return c.UnifiedOrderContext(context.Background(), request)
+}
+
+func (c *Client) UnifiedOrderContext(ctx context.Context, request *UnifiedOrderRequest) (*UnifiedOrderResponse, error) {
 	request.MchId = c.mchId
+	c.setSubMerchant(&request.SubAppId, &request.SubMchId)
 	request.NonceStr = nonceStr()
 	request.TimeExpire = TimeExpire()
-	request.Sign = signStruct(request, c.apiKey)
 
 	if len(request.Body) == 0 {
 		return nil, errors.New("body is zero")
@@ -90,7 +100,7 @@ func (c *Client) UnifiedOrder(request *UnifiedOrderRequest) (*UnifiedOrderRespon
 	switch request.TradeType {
 	case TradeTypeNative:
 	case TradeTypeJs:
-		if len(request.OpenId) == 0 {
+		if len(request.OpenId) == 0 && len(request.SubOpenId) == 0 {
 			return nil, errors.New("openid is zero")
 		}
 	case TradeTypeMWeb:
@@ -101,8 +111,12 @@ func (c *Client) UnifiedOrder(request *UnifiedOrderRequest) (*UnifiedOrderRespon
 		return nil, errors.New("wrong trade_type")
 	}
 
+	var err error
+	if request.Sign, err = c.signStruct(ctx, request); err != nil {
+		return nil, err
+	}
 	var response UnifiedOrderResponse
-	_, err := c.request(unifiedOrderUrl, request, &response)
+	_, err = c.request(ctx, unifiedOrderUrl, request, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -121,14 +135,24 @@ type BrandWCPayRequest struct {
 }
 
 func (c *Client) GetBrandWCPayRequest(resp *UnifiedOrderResponse) string {
+	return c.GetBrandWCPayRequestWithSignType(resp, SignTypeMD5)
+}
+
+// signType需要与统一下单时使用的签名类型一致
+func (c *Client) GetBrandWCPayRequestWithSignType(resp *UnifiedOrderResponse, signType string) string {
 	brandWCPayRequest := &BrandWCPayRequest{
 		AppID:     resp.AppId,
 		Timestamp: strconv.FormatInt(time.Now().Unix(), 10),
 		NonceStr:  nonceStr(),
 		Package:   "prepay_id=" + resp.PrepayId,
-		SignType:  "MD5",
+		SignType:  signType,
+	}
+	paySign, err := c.signStruct(context.Background(), brandWCPayRequest)
+	if err != nil {
+		globalLogger.printf("GetBrandWCPayRequest sign err: %s", err.Error())
+		return ""
 	}
-	brandWCPayRequest.PaySign = signStruct(brandWCPayRequest, c.apiKey)
+	brandWCPayRequest.PaySign = paySign
 	bytes, err := json.Marshal(brandWCPayRequest)
 	if err != nil {
 		globalLogger.printf("%s marshal err: %s", "GetBrandWCPayRequest: ", err.Error())